@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/auth"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// openQueries connects a command to the database.
+func openQueries(cfg Config) (*sql.DB, *database.Queries, error) {
+	db, err := sql.Open(cfg.DBDriver, cfg.DBURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, database.New(db), nil
+}
+
+// findUser looks a user up by id or, failing that, by email.
+func findUser(ctx context.Context, db *database.Queries, ref string) (database.User, error) {
+	var (
+		user database.User
+		err  error
+	)
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = db.GetUserByID(ctx, id)
+	} else {
+		user, err = db.GetUserByEmail(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("user %s not found", ref)
+	}
+	return user, err
+}
+
+// readPassword reads a password from the first line of r, so it doesn't
+// show up in the process list or the shell history.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is required on stdin")
+	}
+	return password, nil
+}
+
+// createAdmin implements `create-admin <email>`.
+func createAdmin(ctx context.Context, cfg Config, args []string, stdin io.Reader, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: create-admin <email> (password on stdin)")
+	}
+
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := db.WithTx(tx)
+
+	user, err := qtx.CreateUser(ctx, database.CreateUserParams{
+		Email:          args[0],
+		HashedPassword: hashed,
+	})
+	if err != nil {
+		return err
+	}
+
+	user, err = qtx.SetUserAdmin(ctx, database.SetUserAdminParams{IsAdmin: true, ID: user.ID})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "created admin %s (%s)\n", user.Email, user.ID)
+	return nil
+}
+
+// resetPassword implements `reset-password <user>`. The user's sessions
+// are revoked, so the old password can't be used to stay logged in.
+func resetPassword(ctx context.Context, cfg Config, args []string, stdin io.Reader, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: reset-password <id|email> (password on stdin)")
+	}
+
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := db.WithTx(tx)
+
+	user, err := findUser(ctx, qtx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, err = qtx.UpdateUser(ctx, database.UpdateUserParams{
+		Email:          user.Email,
+		HashedPassword: hashed,
+		ID:             user.ID,
+	}); err != nil {
+		return err
+	}
+
+	revoked, err := qtx.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "reset the password of %s, revoked %d sessions\n", user.Email, revoked)
+	return nil
+}
+
+// revokeSessions implements `revoke-sessions <user>`. Access tokens stay
+// valid until they expire.
+func revokeSessions(ctx context.Context, cfg Config, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: revoke-sessions <id|email>")
+	}
+
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	user, err := findUser(ctx, db, args[0])
+	if err != nil {
+		return err
+	}
+
+	revoked, err := db.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "revoked %d sessions of %s\n", revoked, user.Email)
+	return nil
+}
+
+// setChirpyRed implements `upgrade <user>` and `downgrade <user>`.
+func setChirpyRed(ctx context.Context, cfg Config, args []string, red bool, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: upgrade|downgrade <id|email>")
+	}
+
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	user, err := findUser(ctx, db, args[0])
+	if err != nil {
+		return err
+	}
+
+	user, err = db.UpgradeUser(ctx, database.UpgradeUserParams{IsChirpyRed: red, ID: user.ID})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s is_chirpy_red=%t\n", user.Email, user.IsChirpyRed)
+	return nil
+}
+
+// purgeTokens implements `purge-tokens`, which deletes the refresh
+// tokens that have expired.
+func purgeTokens(ctx context.Context, cfg Config, w io.Writer) error {
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	purged, err := db.PurgeExpiredRefreshTokens(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "purged %d expired refresh tokens\n", purged)
+	return nil
+}
+
+// redacted is printed instead of a secret.
+const redacted = "REDACTED"
+
+// printConfig implements `config`. It writes cfg in the format of a .env
+// file with the secrets masked.
+func printConfig(cfg Config, w io.Writer) error {
+	v := reflect.ValueOf(cfg)
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+
+		value := fmt.Sprint(v.Field(i).Interface())
+		switch field.Tag.Get("redact") {
+		case "true":
+			if value != "" {
+				value = redacted
+			}
+		case "dsn":
+			value = redactDSN(value)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactDSN masks the password of a database connection string, given as
+// a URL or as libpq's key=value pairs, or all of it when it's neither.
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if strings.Contains(dsn, "://") {
+		return redactURL(dsn)
+	}
+	return redactKeyValues(dsn)
+}
+
+// redactURL masks the password in the user info and the query string.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	// the parameters are rewritten in place, as Query().Encode() would
+	// sort them
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err != nil || name == "password" {
+			params[i] = key + "=" + redacted
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u.String()
+}
+
+// redactKeyValues masks the password of a key=value connection string,
+// where values may be quoted with single quotes and contain escapes.
+func redactKeyValues(dsn string) string {
+	var b strings.Builder
+	rest := strings.TrimLeft(dsn, " \t\n")
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t\n'") {
+			return redacted
+		}
+		value = strings.TrimLeft(value, " \t\n")
+
+		end, ok := valueEnd(value)
+		if !ok {
+			return redacted
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key + "=")
+		if key == "password" {
+			b.WriteString(redacted)
+		} else {
+			b.WriteString(value[:end])
+		}
+		rest = strings.TrimLeft(value[end:], " \t\n")
+	}
+	if b.Len() == 0 {
+		return redacted
+	}
+	return b.String()
+}
+
+// valueEnd returns the length of the value s starts with, which ends at
+// the closing quote of a quoted value and at whitespace otherwise.
+func valueEnd(s string) (int, bool) {
+	quoted := strings.HasPrefix(s, "'")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case quoted && c == '\'' && i > 0:
+			return i + 1, true
+		case !quoted && strings.IndexByte(" \t\n", c) >= 0:
+			return i, true
+		}
+	}
+	return len(s), !quoted
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/transfer"
+)
+
+var errNotAdmin = errors.New("user is not an admin")
+
+// authenticateAdmin is like authenticate, but the user must also be an
+// admin. It returns errNotAdmin when they aren't.
+func (app *App) authenticateAdmin(r *http.Request) (uuid.UUID, error) {
+	id, err := app.authenticate(r)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	user, err := app.db.GetUserByID(r.Context(), id)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if !user.IsAdmin {
+		return uuid.UUID{}, errNotAdmin
+	}
+	return id, nil
+}
+
+// requireAdmin reports whether the request is from an admin, and writes
+// the error response when it isn't.
+func (app *App) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	_, err := app.authenticateAdmin(r)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errNotAdmin):
+		slog.WarnContext(r.Context(), "error authorizing admin", "err", err)
+		responseWithError(w, http.StatusForbidden, "Access denied")
+	default:
+		slog.WarnContext(r.Context(), "error validating token", "err", err)
+		responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+	}
+	return false
+}
+
+func exportHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.requireAdmin(w, r) {
+			return
+		}
+
+		opts := transfer.ExportOptions{Passwords: r.URL.Query().Get("passwords") == "true"}
+
+		// an export of a large database takes longer than the server's
+		// write timeout
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		filename := "chirpy-" + time.Now().UTC().Format("20060102T150405Z") + ".jsonl"
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		counts, err := transfer.New(app.conn, app.txQueries).Export(r.Context(), w, opts)
+		if err != nil {
+			// the status is already sent, the client sees a cut off body
+			slog.ErrorContext(r.Context(), "error exporting", "err", err)
+			return
+		}
+		slog.InfoContext(r.Context(), "exported data",
+			"users", counts.Users, "chirps", counts.Chirps, "follows", counts.Follows,
+			"passwords", opts.Passwords)
+	})
+}
+
+func importHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.requireAdmin(w, r) {
+			return
+		}
+		defer r.Body.Close()
+
+		q := r.URL.Query()
+		opts := transfer.ImportOptions{
+			Policy: transfer.PolicySkip,
+			DryRun: q.Get("dry_run") == "true",
+		}
+		if s := q.Get("policy"); s != "" {
+			policy, err := transfer.ParsePolicy(s)
+			if err != nil {
+				responseWithError(w, http.StatusBadRequest, "Policy must be skip, overwrite or fail")
+				return
+			}
+			opts.Policy = policy
+		}
+		if s := q.Get("batch_size"); s != "" {
+			size, err := strconv.Atoi(s)
+			if err != nil || size < 1 {
+				responseWithError(w, http.StatusBadRequest, "Invalid batch size")
+				return
+			}
+			opts.BatchSize = size
+		}
+
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		result, err := transfer.New(app.conn, app.txQueries).Import(r.Context(), r.Body, opts)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error importing", "err", err)
+			switch {
+			case errors.Is(err, transfer.ErrMalformed):
+				responseWithError(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, transfer.ErrConflict):
+				responseWithError(w, http.StatusConflict, err.Error())
+			default:
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			}
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, result)
+	})
+}
+
+// runExport implements `export [-passwords] [-o file]`.
+func runExport(ctx context.Context, cfg Config, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	passwords := fs.Bool("passwords", false, "include password hashes")
+	out := fs.String("o", "", "write to `file` instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	w := stdout
+	var file *os.File
+	if *out != "" {
+		if file, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	counts, err := transfer.New(conn, db.WithTx).Export(ctx, w, transfer.ExportOptions{Passwords: *passwords})
+	if err != nil {
+		return err
+	}
+	// a failed close can lose the end of the file
+	if file != nil {
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(stderr, "exported %d users, %d chirps, %d follows\n", counts.Users, counts.Chirps, counts.Follows)
+	return nil
+}
+
+// runImport implements `import [-policy skip|overwrite|fail] [-dry-run]
+// [-batch-size n] [file]`. It reads stdin when no file is given.
+func runImport(ctx context.Context, cfg Config, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	policy := fs.String("policy", string(transfer.PolicySkip), "what to do with existing records: skip, overwrite or fail")
+	dryRun := fs.Bool("dry-run", false, "roll everything back at the end")
+	batchSize := fs.Int("batch-size", transfer.DefaultBatchSize, "records per transaction")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("usage: import [flags] [file]")
+	}
+
+	opts := transfer.ImportOptions{DryRun: *dryRun, BatchSize: *batchSize}
+	var err error
+	if opts.Policy, err = transfer.ParsePolicy(*policy); err != nil {
+		return err
+	}
+
+	r := stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	conn, db, err := openQueries(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	result, err := transfer.New(conn, db.WithTx).Import(ctx, r, opts)
+	printImportResult(stdout, result)
+	return err
+}
+
+func printImportResult(w io.Writer, result transfer.ImportResult) {
+	prefix := ""
+	if result.DryRun {
+		prefix = "dry run: "
+	}
+	for _, row := range []struct {
+		label  string
+		counts transfer.Counts
+	}{
+		{"created", result.Created},
+		{"updated", result.Updated},
+		{"skipped", result.Skipped},
+	} {
+		fmt.Fprintf(w, "%s%s %d users, %d chirps, %d follows\n",
+			prefix, row.label, row.counts.Users, row.counts.Chirps, row.counts.Follows)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// relations decide which chirps a viewer gets to see. A block hides both
+// users from each other everywhere. A mute only hides the muted user's
+// chirps from the muter's feeds and notifications, and the muted user is
+// never told about it. Follows unlock followers-only chirps, and the
+// chirps of protected accounts are hidden from everyone but their
+// approved followers.
+type relations struct {
+	viewer    uuid.UUID
+	blocked   map[uuid.UUID]bool
+	muted     map[uuid.UUID]bool
+	following map[uuid.UUID]bool
+	protected map[uuid.UUID]bool
+}
+
+// loadRelations returns the relations of viewer, which are empty for
+// anonymous requests. Only authors are checked for being protected, so
+// they must include the author of every chirp the relations are used on.
+func (app *App) loadRelations(ctx context.Context, viewer uuid.UUID, authors []uuid.UUID) (relations, error) {
+	rel := relations{
+		viewer:    viewer,
+		blocked:   map[uuid.UUID]bool{},
+		muted:     map[uuid.UUID]bool{},
+		following: map[uuid.UUID]bool{},
+		protected: map[uuid.UUID]bool{},
+	}
+
+	if len(authors) > 0 {
+		protected, err := app.db.GetProtectedUserIDs(ctx, database.GetProtectedUserIDsParams{
+			Ids:      authors,
+			ViewerID: viewer,
+		})
+		if err != nil {
+			return relations{}, err
+		}
+		for _, id := range protected {
+			rel.protected[id] = true
+		}
+	}
+
+	if viewer == uuid.Nil {
+		return rel, nil
+	}
+
+	blocked, err := app.db.GetBlockedUserIDs(ctx, viewer)
+	if err != nil {
+		return relations{}, err
+	}
+	for _, id := range blocked {
+		rel.blocked[id] = true
+	}
+
+	muted, err := app.db.GetMutedUserIDs(ctx, viewer)
+	if err != nil {
+		return relations{}, err
+	}
+	for _, id := range muted {
+		rel.muted[id] = true
+	}
+
+	following, err := app.db.GetFollowingIDs(ctx, viewer)
+	if err != nil {
+		return relations{}, err
+	}
+	for _, id := range following {
+		rel.following[id] = true
+	}
+
+	return rel, nil
+}
+
+// canSee reports whether the viewer is allowed to see chirp at all.
+func (rel relations) canSee(chirp database.Chirp) bool {
+	if chirp.UserID == rel.viewer {
+		return true
+	}
+	if rel.blocked[chirp.UserID] || rel.protected[chirp.UserID] {
+		return false
+	}
+	return visibleTo(chirp, rel.following[chirp.UserID])
+}
+
+// shows reports whether chirp is listed for the viewer, which leaves out
+// the chirps of muted users.
+func (rel relations) shows(chirp database.Chirp) bool {
+	return rel.canSee(chirp) && !rel.muted[chirp.UserID]
+}
+
+// inFeed reports whether chirp belongs in the viewer's global feed.
+// Unlisted chirps are only reachable directly.
+func (rel relations) inFeed(chirp database.Chirp) bool {
+	return rel.shows(chirp) && chirp.Visibility != visibilityUnlisted
+}
+
+// chirpAuthors returns the distinct authors of chirps.
+func chirpAuthors(chirps []database.Chirp) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, c := range chirps {
+		if !slices.Contains(ids, c.UserID) {
+			ids = append(ids, c.UserID)
+		}
+	}
+	return ids
+}
+
+// filterChirps returns the chirps for which keep is true.
+func filterChirps(chirps []database.Chirp, keep func(database.Chirp) bool) []database.Chirp {
+	result := []database.Chirp{}
+	for _, c := range chirps {
+		if keep(c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// isBlocked reports whether a and b have blocked each other. Anonymous
+// users are never blocked.
+func (app *App) isBlocked(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	if a == uuid.Nil || b == uuid.Nil {
+		return false, nil
+	}
+	return app.db.IsBlocked(ctx, database.IsBlockedParams{
+		BlockerID: a,
+		BlockedID: b,
+	})
+}
+
+func blockUserHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		if userID == validID {
+			responseWithError(w, http.StatusBadRequest, "You can't block yourself")
+			return
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		// a follow between the two holds a share lock on the followee, so
+		// it either commits first and is removed below, or waits and then
+		// sees the block
+		locked, err := qtx.LockUsers(r.Context(), []uuid.UUID{validID, userID})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error locking users", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		if !slices.Contains(locked, userID) {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		_, err = qtx.CreateBlock(r.Context(), database.CreateBlockParams{
+			BlockerID: validID,
+			BlockedID: userID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error blocking user", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		// blocking ends the follows and follow requests in both directions
+		follows := []database.DeleteFollowParams{
+			{FollowerID: validID, FolloweeID: userID},
+			{FollowerID: userID, FolloweeID: validID},
+		}
+		for _, f := range follows {
+			if _, err = qtx.DeleteFollow(r.Context(), f); err != nil {
+				slog.ErrorContext(r.Context(), "error removing follow", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+			_, err = qtx.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+				RequesterID: f.FollowerID,
+				TargetID:    f.FolloweeID,
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error removing follow request", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing block", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func unblockUserHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		n, err := app.db.DeleteBlock(r.Context(), database.DeleteBlockParams{
+			BlockerID: validID,
+			BlockedID: userID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error unblocking user", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func muteUserHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		if userID == validID {
+			responseWithError(w, http.StatusBadRequest, "You can't mute yourself")
+			return
+		}
+
+		dbUser, err := app.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		_, err = app.db.CreateMute(r.Context(), database.CreateMuteParams{
+			MuterID: validID,
+			MutedID: dbUser.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error muting user", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func unmuteUserHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		n, err := app.db.DeleteMute(r.Context(), database.DeleteMuteParams{
+			MuterID: validID,
+			MutedID: userID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error unmuting user", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func getBlocksHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbUsers, err := app.db.GetBlockedUsers(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving blocked users", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		users, err := app.publicUserResponses(r.Context(), dbUsers)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading users", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Users []PublicUserResponse `json:"users"`
+		}{
+			Users: users,
+		})
+	})
+}
+
+func getMutesHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbUsers, err := app.db.GetMutedUsers(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving muted users", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		users, err := app.publicUserResponses(r.Context(), dbUsers)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading users", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Users []PublicUserResponse `json:"users"`
+		}{
+			Users: users,
+		})
+	})
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const maxCollectionName = 50
+
+func bookmarkChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbChirp, err := app.getVisibleChirp(r.Context(), chirpID, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		err = app.db.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+			UserID:  validID,
+			ChirpID: dbChirp.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error bookmarking chirp", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func unbookmarkChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		err = app.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+			UserID:  validID,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error removing bookmark", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func getBookmarksHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		p, err := parsePage(r)
+		if err != nil {
+			responseWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rows, err := app.db.GetBookmarkedChirps(r.Context(), database.GetBookmarkedChirpsParams{
+			UserID:     validID,
+			BeforeTime: p.BeforeTime,
+			BeforeID:   p.BeforeID,
+			PageSize:   p.Size,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving bookmarks", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		dbChirps := make([]database.Chirp, len(rows))
+		var next string
+		for i, row := range rows {
+			dbChirps[i] = row.Chirp
+			next = p.nextCursor(len(rows), row.BookmarkedAt, row.Chirp.ID)
+		}
+
+		rel, err := app.loadRelations(r.Context(), validID, chirpAuthors(dbChirps))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading relations", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		dbChirps = filterChirps(dbChirps, rel.canSee)
+
+		chirps, err := app.chirpResponses(r.Context(), dbChirps, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Chrips     []ChirpResponse `json:"chirps"`
+			NextCursor string          `json:"next_cursor"`
+		}{
+			Chrips:     chirps,
+			NextCursor: next,
+		})
+	})
+}
+
+// loadBookmarks marks the chirps in resp that viewer has bookmarked.
+// Bookmarks are private, so nothing is loaded for other users.
+func (app *App) loadBookmarks(ctx context.Context, resp []ChirpResponse, ids []uuid.UUID,
+	index map[uuid.UUID]int, viewer uuid.UUID) error {
+	if viewer == uuid.Nil {
+		return nil
+	}
+
+	bookmarked, err := app.db.GetBookmarkedChirpIDs(ctx, database.GetBookmarkedChirpIDsParams{
+		UserID:   viewer,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range bookmarked {
+		b := true
+		resp[index[id]].Bookmarked = &b
+	}
+
+	return nil
+}
+
+type CollectionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+func newCollectionResponse(c database.Collection) CollectionResponse {
+	return CollectionResponse{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Name:      c.Name,
+	}
+}
+
+type CollectionRequest struct {
+	Name string `json:"name" required:"true"`
+}
+
+// checkCollectionName returns a message for the client when name can't be
+// used for a collection.
+func checkCollectionName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxCollectionName {
+		return "Collection name must be between 1 and 50 characters"
+	}
+	return ""
+}
+
+func createCollectionHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params CollectionRequest
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if problem := checkCollectionName(params.Name); problem != "" {
+			responseWithError(w, http.StatusBadRequest, problem)
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbCollection, err := app.db.CreateCollection(r.Context(), database.CreateCollectionParams{
+			UserID: validID,
+			Name:   strings.TrimSpace(params.Name),
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating collection", "err", err)
+			responseWithError(w, http.StatusConflict, "Collection already exists")
+			return
+		}
+
+		responseWithJSON(w, http.StatusCreated, newCollectionResponse(dbCollection))
+	})
+}
+
+func getCollectionsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbCollections, err := app.db.GetCollections(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving collections", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		collections := make([]CollectionResponse, len(dbCollections))
+		for i, c := range dbCollections {
+			collections[i] = newCollectionResponse(c)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Collections []CollectionResponse `json:"collections"`
+		}{
+			Collections: collections,
+		})
+	})
+}
+
+func getCollectionByIDHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collectionID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing collection id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		p, err := parsePage(r)
+		if err != nil {
+			responseWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dbCollection, err := app.db.GetCollectionByID(r.Context(), database.GetCollectionByIDParams{
+			ID:     collectionID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving collection", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		rows, err := app.db.GetCollectionChirps(r.Context(), database.GetCollectionChirpsParams{
+			CollectionID: dbCollection.ID,
+			UserID:       validID,
+			BeforeTime:   p.BeforeTime,
+			BeforeID:     p.BeforeID,
+			PageSize:     p.Size,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving collection chirps", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		dbChirps := make([]database.Chirp, len(rows))
+		var next string
+		for i, row := range rows {
+			dbChirps[i] = row.Chirp
+			next = p.nextCursor(len(rows), row.FiledAt, row.Chirp.ID)
+		}
+
+		rel, err := app.loadRelations(r.Context(), validID, chirpAuthors(dbChirps))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading relations", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		dbChirps = filterChirps(dbChirps, rel.canSee)
+
+		chirps, err := app.chirpResponses(r.Context(), dbChirps, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			CollectionResponse
+			Chrips     []ChirpResponse `json:"chirps"`
+			NextCursor string          `json:"next_cursor"`
+		}{
+			CollectionResponse: newCollectionResponse(dbCollection),
+			Chrips:             chirps,
+			NextCursor:         next,
+		})
+	})
+}
+
+func renameCollectionHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params CollectionRequest
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if problem := checkCollectionName(params.Name); problem != "" {
+			responseWithError(w, http.StatusBadRequest, problem)
+			return
+		}
+
+		collectionID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing collection id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbCollection, err := app.db.RenameCollection(r.Context(), database.RenameCollectionParams{
+			Name:   strings.TrimSpace(params.Name),
+			ID:     collectionID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error renaming collection", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, newCollectionResponse(dbCollection))
+	})
+}
+
+func deleteCollectionByID(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collectionID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing collection id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		_, err = app.db.DeleteCollection(r.Context(), database.DeleteCollectionParams{
+			ID:     collectionID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error deleting collection", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func addCollectionChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type requestAddChirp struct {
+			ChirpID uuid.UUID `json:"chirp_id"`
+		}
+		var params requestAddChirp
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		collectionID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing collection id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		// the foreign keys reject collections of other users and chirps
+		// that are not bookmarked
+		err = app.db.AddChirpToCollection(r.Context(), database.AddChirpToCollectionParams{
+			CollectionID: collectionID,
+			UserID:       validID,
+			ChirpID:      params.ChirpID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error filing chirp", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Only bookmarked chirps can be added to your collections")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func removeCollectionChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collectionID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing collection id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		n, err := app.db.RemoveChirpFromCollection(r.Context(), database.RemoveChirpFromCollectionParams{
+			CollectionID: collectionID,
+			UserID:       validID,
+			ChirpID:      chirpID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error removing chirp from collection", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// runCommand runs the subcommand named by args[0] instead of the server.
+func runCommand(ctx context.Context, cfg Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, cfg, args[1:], os.Stdout)
+	case "create-admin":
+		return createAdmin(ctx, cfg, args[1:], os.Stdin, os.Stdout)
+	case "reset-password":
+		return resetPassword(ctx, cfg, args[1:], os.Stdin, os.Stdout)
+	case "revoke-sessions":
+		return revokeSessions(ctx, cfg, args[1:], os.Stdout)
+	case "upgrade":
+		return setChirpyRed(ctx, cfg, args[1:], true, os.Stdout)
+	case "downgrade":
+		return setChirpyRed(ctx, cfg, args[1:], false, os.Stdout)
+	case "purge-tokens":
+		return purgeTokens(ctx, cfg, os.Stdout)
+	case "export":
+		return runExport(ctx, cfg, args[1:], os.Stdout, os.Stderr)
+	case "import":
+		return runImport(ctx, cfg, args[1:], os.Stdin, os.Stdout)
+	case "replay":
+		return runReplay(ctx, cfg, args[1:], os.Stdin, os.Stdout)
+	case "config":
+		return printConfig(cfg, os.Stdout)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
@@ -3,26 +3,121 @@ package main
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/prchop/chirpysrv/internal/database"
+	"github.com/prchop/chirpysrv/internal/media"
+	"github.com/prchop/chirpysrv/internal/metrics"
+	"github.com/prchop/chirpysrv/internal/pubsub"
+	"github.com/prchop/chirpysrv/internal/storage"
+	"github.com/prchop/chirpysrv/internal/tracing"
 )
 
+// Config is read from the environment. Fields tagged redact are masked
+// when the config is printed, "dsn" masks only the password of a
+// connection string.
 type Config struct {
 	Port      string `env:"PORT"`
 	DBDriver  string `env:"DB_DRIVER"`
-	DBURI     string `env:"DB_URI"`
+	DBURI     string `env:"DB_URI" redact:"dsn"`
 	Platform  string `env:"PLATFORM"`
-	JWTSecret string `env:"JWT_SECRET"`
-	PolkaKey  string `env:"POLKA_KEY"`
+	JWTSecret string `env:"JWT_SECRET" redact:"true"`
+	PolkaKey  string `env:"POLKA_KEY" redact:"true"`
+
+	// MigrateOnStart applies pending migrations before serving. An
+	// advisory lock keeps replicas starting together from racing.
+	MigrateOnStart bool `env:"MIGRATE_ON_START" envDefault:"false"`
+
+	// Timeouts of the http.Server. GET /api/stream lifts the write
+	// timeout for itself.
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT" envDefault:"15s"`
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"5s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" envDefault:"30s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" envDefault:"120s"`
+
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	// ShutdownDelay keeps serving with /readyz failing before the
+	// shutdown starts, so load balancers stop sending traffic first.
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY" envDefault:"0s"`
+
+	// LogLevel is one of debug, info, warn or error, LogFormat is json or
+	// text.
+	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat string `env:"LOG_FORMAT" envDefault:"json"`
+
+	// TraceExporter is none, otlp or stdout. The OTLP exporter reads the
+	// standard OTEL_EXPORTER_OTLP_* variables.
+	TraceExporter string `env:"TRACE_EXPORTER" envDefault:"none"`
+
+	// ChirpEditWindow limits how long after creation a chirp can be
+	// edited. Zero disables the limit.
+	ChirpEditWindow time.Duration `env:"CHIRP_EDIT_WINDOW"`
+
+	// TrashRetention is how long soft-deleted users and chirps can be
+	// restored before the purge worker removes them for good.
+	TrashRetention time.Duration `env:"TRASH_RETENTION" envDefault:"720h"`
+	PurgeInterval  time.Duration `env:"PURGE_INTERVAL" envDefault:"1h"`
+
+	// PublishInterval is how often scheduled chirps are checked.
+	PublishInterval time.Duration `env:"PUBLISH_INTERVAL" envDefault:"30s"`
+
+	// PinLimit is how many chirps a user can pin to their profile,
+	// PinLimitRed applies to Chirpy Red members instead.
+	PinLimit    int `env:"PIN_LIMIT" envDefault:"1"`
+	PinLimitRed int `env:"PIN_LIMIT_RED" envDefault:"5"`
+
+	// MediaBackend selects where uploads are stored, "fs" or "s3".
+	MediaBackend  string        `env:"MEDIA_BACKEND" envDefault:"fs"`
+	MediaDir      string        `env:"MEDIA_DIR" envDefault:"./media"`
+	MediaMaxBytes int64         `env:"MEDIA_MAX_BYTES" envDefault:"5242880"`
+	MediaSecret   string        `env:"MEDIA_SECRET" redact:"true"`
+	MediaURLTTL   time.Duration `env:"MEDIA_URL_TTL" envDefault:"24h"`
+
+	S3Endpoint  string `env:"S3_ENDPOINT"`
+	S3Region    string `env:"S3_REGION"`
+	S3Bucket    string `env:"S3_BUCKET"`
+	S3AccessKey string `env:"S3_ACCESS_KEY" redact:"true"`
+	S3SecretKey string `env:"S3_SECRET_KEY" redact:"true"`
+}
+
+// validate rejects the values the server can't run with, such as
+// intervals a ticker would panic on.
+func (cfg Config) validate() error {
+	positive := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"TRASH_RETENTION", cfg.TrashRetention},
+		{"PURGE_INTERVAL", cfg.PurgeInterval},
+		{"PUBLISH_INTERVAL", cfg.PublishInterval},
+	}
+	for _, d := range positive {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", d.name, d.value)
+		}
+	}
+	return nil
 }
 
 type App struct {
-	db      *database.Queries
-	srvHits atomic.Int32
-	config  Config
+	conn     *sql.DB
+	db       *database.Queries
+	blobs    storage.BlobStore
+	signer   *media.Signer
+	events   *pubsub.Hub[uuid.UUID, StreamEvent]
+	draining chan struct{} // closed on shutdown to end open streams
+	metrics  *metrics.Metrics
+	workers  *workerRegistry
+	srvHits  atomic.Int32
+	config   Config
+
+	shuttingDown atomic.Bool
 }
 
 func (app *App) MiddlewareMetricsInc(next http.Handler) http.Handler {
@@ -60,7 +155,7 @@ func (app *App) HandlerReset() http.Handler {
 
 		// delete all users
 		if err := app.db.DeleteAllUsers(r.Context()); err != nil {
-			log.Printf("error deleting users: %v", err)
+			slog.ErrorContext(r.Context(), "error deleting users", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -76,11 +171,62 @@ func NewApp(cfg Config) (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	queries := database.New(db)
+	queries := database.New(tracing.WrapDB(db))
+
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// media URLs fall back to being signed with the JWT secret
+	secret := cfg.MediaSecret
+	if secret == "" {
+		secret = cfg.JWTSecret
+	}
 
 	return &App{
-		db:      queries,
-		srvHits: atomic.Int32{},
-		config:  cfg,
+		conn:     db,
+		db:       queries,
+		blobs:    blobs,
+		signer:   media.NewSigner(secret, cfg.MediaURLTTL),
+		events:   pubsub.New[uuid.UUID, StreamEvent](16),
+		draining: make(chan struct{}),
+		metrics:  metrics.New(db),
+		workers:  newWorkerRegistry(),
+		srvHits:  atomic.Int32{},
+		config:   cfg,
 	}, nil
 }
+
+// closeStreams ends the open event streams so a shutdown doesn't wait
+// for them. Clients reconnect to another instance.
+func (app *App) closeStreams() {
+	close(app.draining)
+}
+
+// Close releases the database connections.
+func (app *App) Close() error {
+	return app.conn.Close()
+}
+
+// txQueries returns the queries run in tx, traced like app.db.
+func (app *App) txQueries(tx *sql.Tx) *database.Queries {
+	return database.New(tracing.WrapDB(tx))
+}
+
+func newBlobStore(cfg Config) (storage.BlobStore, error) {
+	switch cfg.MediaBackend {
+	case "fs":
+		return storage.NewFileStore(cfg.MediaDir)
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		}, nil)
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", cfg.MediaBackend)
+	}
+}
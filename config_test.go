@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		TrashRetention:  720 * time.Hour,
+		PurgeInterval:   time.Hour,
+		PublishInterval: 30 * time.Second,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(*Config) {}, false},
+		{"zero retention", func(c *Config) { c.TrashRetention = 0 }, true},
+		{"zero purge interval", func(c *Config) { c.PurgeInterval = 0 }, true},
+		{"negative purge interval", func(c *Config) { c.PurgeInterval = -time.Second }, true},
+		{"zero publish interval", func(c *Config) { c.PublishInterval = 0 }, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid
+			tc.modify(&cfg)
+			err := cfg.validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got err %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
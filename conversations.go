@@ -0,0 +1,650 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const (
+	dmPolicyEveryone  = "everyone"
+	dmPolicyFollowing = "following"
+
+	streamEventMessage = "message"
+	streamEventRead    = "read"
+
+	// maxConversationSize counts the user starting the conversation.
+	maxConversationSize = 10
+	maxConversationName = 50
+	maxMessageLength    = 1000
+)
+
+type ParticipantResponse struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	JoinedAt    time.Time  `json:"joined_at"`
+	LastReadAt  *time.Time `json:"last_read_at"`
+	UnreadCount int64      `json:"unread_count"`
+}
+
+type ConversationResponse struct {
+	ID           uuid.UUID             `json:"id"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	Name         string                `json:"name"`
+	IsGroup      bool                  `json:"is_group"`
+	UnreadCount  int64                 `json:"unread_count"`
+	Participants []ParticipantResponse `json:"participants"`
+}
+
+func newConversationResponse(c database.Conversation) ConversationResponse {
+	return ConversationResponse{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+		Name:         c.Name,
+		IsGroup:      !c.DirectKey.Valid,
+		Participants: []ParticipantResponse{},
+	}
+}
+
+type MessageResponse struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+func newMessageResponse(m database.Message) MessageResponse {
+	return MessageResponse{
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Body:           m.Body,
+	}
+}
+
+// ReadReceipt is pushed to the other participants when a user reads a
+// conversation.
+type ReadReceipt struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	LastReadAt     time.Time `json:"last_read_at"`
+}
+
+// conversationResponses builds the responses for convs with their
+// participants, read receipts and unread counts. UnreadCount is the one
+// of viewer.
+func (app *App) conversationResponses(ctx context.Context, convs []database.Conversation,
+	viewer uuid.UUID) ([]ConversationResponse, error) {
+	resp := make([]ConversationResponse, len(convs))
+	ids := make([]uuid.UUID, len(convs))
+	index := make(map[uuid.UUID]int, len(convs))
+	for i, c := range convs {
+		resp[i] = newConversationResponse(c)
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+
+	if len(convs) == 0 {
+		return resp, nil
+	}
+
+	participants, err := app.db.GetConversationParticipants(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range participants {
+		i := index[p.ConversationID]
+
+		var lastReadAt *time.Time
+		if p.LastReadAt.Valid {
+			lastReadAt = &p.LastReadAt.Time
+		}
+
+		resp[i].Participants = append(resp[i].Participants, ParticipantResponse{
+			UserID:      p.UserID,
+			JoinedAt:    p.JoinedAt,
+			LastReadAt:  lastReadAt,
+			UnreadCount: p.UnreadCount,
+		})
+		if p.UserID == viewer {
+			resp[i].UnreadCount = p.UnreadCount
+		}
+	}
+
+	return resp, nil
+}
+
+// canMessage reports whether sender may send direct messages to
+// recipient. Blocks in either direction prevent it, and so does a
+// recipient who only accepts messages from people they follow.
+func (app *App) canMessage(ctx context.Context, sender uuid.UUID, recipient database.User) (bool, error) {
+	blocked, err := app.isBlocked(ctx, sender, recipient.ID)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	if recipient.DmPolicy != dmPolicyFollowing {
+		return true, nil
+	}
+
+	return app.db.IsFollowing(ctx, database.IsFollowingParams{
+		FollowerID: recipient.ID,
+		FolloweeID: sender,
+	})
+}
+
+// directKey identifies the one-to-one conversation between a and b.
+func directKey(a, b uuid.UUID) sql.NullString {
+	ids := []string{a.String(), b.String()}
+	slices.Sort(ids)
+	return sql.NullString{String: strings.Join(ids, ":"), Valid: true}
+}
+
+// publish pushes ev to the participants of convs, except for skip.
+func (app *App) publish(convs []ConversationResponse, ev StreamEvent, skip ...uuid.UUID) {
+	for _, c := range convs {
+		for _, p := range c.Participants {
+			if !slices.Contains(skip, p.UserID) {
+				app.events.Publish(p.UserID, ev)
+			}
+		}
+	}
+}
+
+func createConversationHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type requestCreateConversation struct {
+			ParticipantIDs []uuid.UUID `json:"participant_ids"`
+			Name           string      `json:"name"`
+		}
+		var params requestCreateConversation
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		var others []uuid.UUID
+		for _, id := range params.ParticipantIDs {
+			if id != validID && !slices.Contains(others, id) {
+				others = append(others, id)
+			}
+		}
+
+		if len(others) == 0 || len(others)+1 > maxConversationSize {
+			responseWithError(w, http.StatusBadRequest, "A conversation needs between 2 and 10 participants")
+			return
+		}
+
+		name := strings.TrimSpace(params.Name)
+		if len(name) > maxConversationName {
+			responseWithError(w, http.StatusBadRequest, "Conversation name is too long")
+			return
+		}
+
+		for _, id := range others {
+			dbUser, err := app.db.GetUserByID(r.Context(), id)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+				responseWithError(w, http.StatusBadRequest, "User not found")
+				return
+			}
+
+			ok, err := app.canMessage(r.Context(), validID, dbUser)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error checking dm permissions", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+			if !ok {
+				responseWithError(w, http.StatusForbidden, "You can't message this user")
+				return
+			}
+		}
+
+		if len(others) > 1 {
+			blocked, err := app.db.HasBlockAmong(r.Context(), others)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error checking blocks", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+			if blocked {
+				responseWithError(w, http.StatusForbidden, "Some of these users have blocked each other")
+				return
+			}
+		}
+
+		// one-to-one conversations are reused, groups are always new
+		var key sql.NullString
+		if len(others) == 1 {
+			key = directKey(validID, others[0])
+			name = ""
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		dbConversation, err := qtx.CreateConversation(r.Context(), database.CreateConversationParams{
+			DirectKey: key,
+			Name:      name,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating conversation", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		for _, id := range append(others, validID) {
+			err = qtx.AddConversationParticipant(r.Context(), database.AddConversationParticipantParams{
+				ConversationID: dbConversation.ID,
+				UserID:         id,
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error adding participant", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing conversation", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		conversations, err := app.conversationResponses(r.Context(),
+			[]database.Conversation{dbConversation}, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading conversation", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusCreated, conversations[0])
+	})
+}
+
+func getConversationsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		p, err := parsePage(r)
+		if err != nil {
+			responseWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dbConversations, err := app.db.GetConversations(r.Context(), database.GetConversationsParams{
+			UserID:     validID,
+			BeforeTime: p.BeforeTime,
+			BeforeID:   p.BeforeID,
+			PageSize:   p.Size,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving conversations", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		conversations, err := app.conversationResponses(r.Context(), dbConversations, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading conversations", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		var next string
+		if len(dbConversations) > 0 {
+			last := dbConversations[len(dbConversations)-1]
+			next = p.nextCursor(len(dbConversations), last.UpdatedAt, last.ID)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Conversations []ConversationResponse `json:"conversations"`
+			NextCursor    string                 `json:"next_cursor"`
+		}{
+			Conversations: conversations,
+			NextCursor:    next,
+		})
+	})
+}
+
+func getConversationByIDHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conversationID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing conversation id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbConversation, err := app.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+			ID:     conversationID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving conversation", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		conversations, err := app.conversationResponses(r.Context(),
+			[]database.Conversation{dbConversation}, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading conversation", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, conversations[0])
+	})
+}
+
+func getMessagesHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conversationID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing conversation id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		p, err := parsePage(r)
+		if err != nil {
+			responseWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dbConversation, err := app.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+			ID:     conversationID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving conversation", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		dbMessages, err := app.db.GetMessages(r.Context(), database.GetMessagesParams{
+			ConversationID: dbConversation.ID,
+			BeforeTime:     p.BeforeTime,
+			BeforeID:       p.BeforeID,
+			PageSize:       p.Size,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving messages", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		// messages of blocked users stay hidden in groups, the cursor
+		// still moves past them
+		blocked, err := app.db.GetBlockedUserIDs(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving blocks", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		messages := make([]MessageResponse, 0, len(dbMessages))
+		var next string
+		for _, m := range dbMessages {
+			if !slices.Contains(blocked, m.SenderID) {
+				messages = append(messages, newMessageResponse(m))
+			}
+			next = p.nextCursor(len(dbMessages), m.CreatedAt, m.ID)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Messages   []MessageResponse `json:"messages"`
+			NextCursor string            `json:"next_cursor"`
+		}{
+			Messages:   messages,
+			NextCursor: next,
+		})
+	})
+}
+
+func sendMessageHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type requestSendMessage struct {
+			Body string `json:"body"`
+		}
+		var params requestSendMessage
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if strings.TrimSpace(params.Body) == "" || len(params.Body) > maxMessageLength {
+			responseWithError(w, http.StatusBadRequest, "Message must be between 1 and 1000 characters")
+			return
+		}
+
+		conversationID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing conversation id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbConversation, err := app.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+			ID:     conversationID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving conversation", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		conversations, err := app.conversationResponses(r.Context(),
+			[]database.Conversation{dbConversation}, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading conversation", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// a block or a changed dm policy closes one-to-one conversations.
+		// Groups stay open, but blocked users don't get the message.
+		if dbConversation.DirectKey.Valid {
+			for _, p := range conversations[0].Participants {
+				if p.UserID == validID {
+					continue
+				}
+
+				recipient, err := app.db.GetUserByID(r.Context(), p.UserID)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+					responseWithError(w, http.StatusForbidden, "You can't message this user")
+					return
+				}
+
+				ok, err := app.canMessage(r.Context(), validID, recipient)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "error checking dm permissions", "err", err)
+					responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+					return
+				}
+				if !ok {
+					responseWithError(w, http.StatusForbidden, "You can't message this user")
+					return
+				}
+			}
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		dbMessage, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{
+			ConversationID: dbConversation.ID,
+			SenderID:       validID,
+			Body:           params.Body,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating message", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if err = qtx.TouchConversation(r.Context(), dbConversation.ID); err != nil {
+			slog.ErrorContext(r.Context(), "error updating conversation", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// the sender has read everything up to their own message
+		if _, err = qtx.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: dbConversation.ID,
+			UserID:         validID,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "error marking conversation read", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing message", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		blocked, err := app.db.GetBlockedUserIDs(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving blocks", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		sentMessage := newMessageResponse(dbMessage)
+		app.publish(conversations, StreamEvent{Type: streamEventMessage, Data: sentMessage},
+			append(blocked, validID)...)
+
+		responseWithJSON(w, http.StatusCreated, sentMessage)
+	})
+}
+
+func readConversationHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conversationID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing conversation id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbParticipant, err := app.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: conversationID,
+			UserID:         validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error marking conversation read", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		dbConversation, err := app.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+			ID:     dbParticipant.ConversationID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving conversation", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		conversations, err := app.conversationResponses(r.Context(),
+			[]database.Conversation{dbConversation}, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading conversation", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		app.publish(conversations, StreamEvent{
+			Type: streamEventRead,
+			Data: ReadReceipt{
+				ConversationID: dbParticipant.ConversationID,
+				UserID:         validID,
+				LastReadAt:     dbParticipant.LastReadAt.Time,
+			},
+		}, validID)
+
+		responseWithJSON(w, http.StatusOK, conversations[0])
+	})
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+type DraftResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Body      string    `json:"body"`
+}
+
+func newDraftResponse(draft database.Draft) DraftResponse {
+	return DraftResponse{
+		ID:        draft.ID,
+		CreatedAt: draft.CreatedAt,
+		UpdatedAt: draft.UpdatedAt,
+		UserID:    draft.UserID,
+		Body:      draft.Body,
+	}
+}
+
+type DraftRequest struct {
+	Body string `json:"body"`
+}
+
+func createDraftHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params DraftRequest
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbDraft, err := app.db.CreateDraft(r.Context(), database.CreateDraftParams{
+			UserID: validID,
+			Body:   params.Body,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating draft", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusCreated, newDraftResponse(dbDraft))
+	})
+}
+
+func getDraftsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbDrafts, err := app.db.GetDrafts(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving drafts", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		drafts := make([]DraftResponse, len(dbDrafts))
+		for i, d := range dbDrafts {
+			drafts[i] = newDraftResponse(d)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Drafts []DraftResponse `json:"drafts"`
+		}{
+			Drafts: drafts,
+		})
+	})
+}
+
+func getDraftByIDHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing draft id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbDraft, err := app.db.GetDraftByID(r.Context(), database.GetDraftByIDParams{
+			ID:     draftID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving draft", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, newDraftResponse(dbDraft))
+	})
+}
+
+func updateDraftHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params DraftRequest
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		draftID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing draft id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbDraft, err := app.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+			Body:   params.Body,
+			ID:     draftID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error updating draft", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, newDraftResponse(dbDraft))
+	})
+}
+
+func deleteDraftByID(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing draft id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		_, err = app.db.DeleteDraftByID(r.Context(), database.DeleteDraftByIDParams{
+			ID:     draftID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error deleting draft", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func publishDraftHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing draft id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		// lock the draft so publishing it twice from two devices only
+		// creates one chirp
+		dbDraft, err := qtx.GetDraftByIDForUpdate(r.Context(), database.GetDraftByIDForUpdateParams{
+			ID:     draftID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving draft", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		str, problem := checkChirpBody(dbDraft.Body)
+		if problem != "" {
+			responseWithError(w, http.StatusBadRequest, problem)
+			return
+		}
+
+		dbChirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+			Body:       str,
+			UserID:     validID,
+			Visibility: visibilityPublic,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating chirp", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		_, err = qtx.DeleteDraftByID(r.Context(), database.DeleteDraftByIDParams{
+			ID:     dbDraft.ID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error deleting draft", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing draft publish", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		app.metrics.ChirpsCreated.Inc()
+
+		createdChirp, err := app.chirpResponse(r.Context(), dbChirp, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusCreated, createdChirp)
+	})
+}
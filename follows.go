@@ -0,0 +1,275 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+func followUserHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		if userID == validID {
+			responseWithError(w, http.StatusBadRequest, "You can't follow yourself")
+			return
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		// holds off a switch to public until the request is stored, so it
+		// gets approved with the others
+		dbUser, err := qtx.GetUserByIDForShare(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		// blocked users can't follow, and aren't told why. The lock above
+		// makes a block of either user wait for this transaction.
+		blocked, err := qtx.IsBlocked(r.Context(), database.IsBlockedParams{
+			BlockerID: validID,
+			BlockedID: dbUser.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error checking block", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		if blocked {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		following, err := qtx.IsFollowing(r.Context(), database.IsFollowingParams{
+			FollowerID: validID,
+			FolloweeID: dbUser.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error checking follow", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// protected accounts approve their followers first
+		status := http.StatusNoContent
+		if dbUser.Protected && !following {
+			_, err = qtx.CreateFollowRequest(r.Context(), database.CreateFollowRequestParams{
+				RequesterID: validID,
+				TargetID:    dbUser.ID,
+			})
+			status = http.StatusAccepted
+		} else {
+			_, err = qtx.CreateFollow(r.Context(), database.CreateFollowParams{
+				FollowerID: validID,
+				FolloweeID: dbUser.ID,
+			})
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error following user", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing follow", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, status)
+	})
+}
+
+func unfollowUserHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		n, err := app.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			FollowerID: validID,
+			FolloweeID: userID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error unfollowing user", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			// unfollowing while the request is pending withdraws it
+			n, err = app.db.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+				RequesterID: validID,
+				TargetID:    userID,
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error withdrawing follow request", "err", err)
+				responseWithError(w, http.StatusBadRequest, "Something went wrong")
+				return
+			}
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func getFollowRequestsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbUsers, err := app.db.GetFollowRequests(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving follow requests", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		users, err := app.publicUserResponses(r.Context(), dbUsers)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading users", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Users []PublicUserResponse `json:"users"`
+		}{
+			Users: users,
+		})
+	})
+}
+
+func approveFollowRequestHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		n, err := qtx.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+			RequesterID: userID,
+			TargetID:    validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error approving follow request", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		_, err = qtx.CreateFollow(r.Context(), database.CreateFollowParams{
+			FollowerID: userID,
+			FolloweeID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error approving follow request", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing follow", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func rejectFollowRequestHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		// the requester is not told about the rejection
+		n, err := app.db.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+			RequesterID: userID,
+			TargetID:    validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rejecting follow request", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"slices"
@@ -12,14 +16,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/prchop/chirpysrv/internal/auth"
 	"github.com/prchop/chirpysrv/internal/database"
+	"github.com/prchop/chirpysrv/internal/logging"
+	"github.com/prchop/chirpysrv/internal/metrics"
 )
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
-}
-
 func appHandler(path string) http.Handler {
 	return http.StripPrefix("/app", http.FileServer(http.Dir(path)))
 }
@@ -28,21 +28,21 @@ func refreshHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reftoken, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			log.Printf("error retrieving refresh token: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving refresh token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		dbUser, err := app.db.GetUserByRefreshToken(r.Context(), reftoken)
 		if err != nil {
-			log.Printf("error retrieving user with refresh token: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving user with refresh token", "err", err)
 			responseWithError(w, http.StatusUnauthorized, "Token didn't exist or already expired")
 			return
 		}
 
 		token, err := auth.MakeJWT(dbUser.ID, app.config.JWTSecret, time.Hour)
 		if err != nil {
-			log.Printf("error generating access token: %v", err)
+			slog.ErrorContext(r.Context(), "error generating access token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -59,13 +59,13 @@ func revokeHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reftoken, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			log.Printf("error retrieving refresh token: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving refresh token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		if _, err = app.db.RevokeRefreshToken(r.Context(), reftoken); err != nil {
-			log.Printf("error revoking refresh token: %v", err)
+			slog.ErrorContext(r.Context(), "error revoking refresh token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Token didn't exist or already revoked")
 			return
 		}
@@ -97,21 +97,51 @@ func validate(s any) map[string]string {
 	return errors
 }
 
+// PublicUserResponse is what anyone can see about a user. It must never
+// carry the email or other private fields.
+type PublicUserResponse struct {
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	IsChirpyRed    bool           `json:"is_chirpy_red"`
+	Bio            string         `json:"bio"`
+	Location       string         `json:"location"`
+	Website        string         `json:"website"`
+	Protected      bool           `json:"protected"`
+	Avatar         *MediaResponse `json:"avatar"`
+	Banner         *MediaResponse `json:"banner"`
+	FollowerCount  int64          `json:"follower_count"`
+	FollowingCount int64          `json:"following_count"`
+	ChirpCount     int64          `json:"chirp_count"`
+}
+
+// UserResponse is the representation a user gets of themselves.
 type UserResponse struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	PublicUserResponse
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+	DMPolicy  string    `json:"dm_policy"`
+	IsAdmin   bool      `json:"is_admin"`
 }
 
-func newUserResponse(user database.User) UserResponse {
-	return UserResponse{
+func newPublicUserResponse(user database.User) PublicUserResponse {
+	return PublicUserResponse{
 		ID:          user.ID,
 		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
 		IsChirpyRed: user.IsChirpyRed,
+		Bio:         user.Bio,
+		Location:    user.Location,
+		Website:     user.Website,
+		Protected:   user.Protected,
+	}
+}
+
+func newUserResponse(user database.User) UserResponse {
+	return UserResponse{
+		PublicUserResponse: newPublicUserResponse(user),
+		UpdatedAt:          user.UpdatedAt,
+		Email:              user.Email,
+		DMPolicy:           user.DmPolicy,
+		IsAdmin:            user.IsAdmin,
 	}
 }
 
@@ -137,7 +167,7 @@ func userHandler(app *App) http.Handler {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&params); err != nil {
-			log.Printf("error decoding: %v", err)
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -150,7 +180,7 @@ func userHandler(app *App) http.Handler {
 
 		password, err := auth.HashPassword(params.Password)
 		if err != nil {
-			log.Printf("error hashing password: %v", err)
+			slog.ErrorContext(r.Context(), "error hashing password", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -162,12 +192,17 @@ func userHandler(app *App) http.Handler {
 			},
 		)
 		if err != nil {
-			log.Printf("error generating user: %v", err)
+			slog.ErrorContext(r.Context(), "error creating user", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		createdUser := newUserResponse(dbUser)
+		createdUser, err := app.userResponse(r.Context(), dbUser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading user", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
 		responseWithJSON(w, http.StatusCreated, createdUser)
 	})
 }
@@ -180,7 +215,7 @@ func userLoginHandler(app *App) http.Handler {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&params); err != nil {
-			log.Printf("error decoding: %v", err)
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -193,13 +228,15 @@ func userLoginHandler(app *App) http.Handler {
 
 		dbUser, err := app.db.GetUserByEmail(r.Context(), params.Email)
 		if err != nil {
-			log.Printf("error retrieving user: %v", err)
+			slog.WarnContext(r.Context(), "error retrieving user", "err", err)
+			app.metrics.FailedLogins.Inc()
 			responseWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 			return
 		}
 
 		if err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword); err != nil {
-			log.Printf("error checking password: %v", err)
+			slog.WarnContext(r.Context(), "error checking password", "err", err)
+			app.metrics.FailedLogins.Inc()
 			responseWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 			return
 		}
@@ -207,14 +244,14 @@ func userLoginHandler(app *App) http.Handler {
 		// token should expire after 1 hour
 		token, err := auth.MakeJWT(dbUser.ID, app.config.JWTSecret, time.Hour)
 		if err != nil {
-			log.Printf("error generating access token: %v", err)
+			slog.ErrorContext(r.Context(), "error generating access token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		reftoken, err := auth.MakeRefreshToken()
 		if err != nil {
-			log.Printf("error generating refresh token: %v", err)
+			slog.ErrorContext(r.Context(), "error generating refresh token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -227,14 +264,20 @@ func userLoginHandler(app *App) http.Handler {
 				ExpiresAt: time.Now().AddDate(0, 0, 60),
 			})
 		if err != nil {
-			log.Printf("error retrieving user: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
 			responseWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 			return
 		}
 
-		loggedInUser := newUserResponse(dbUser)
+		loggedInUser, err := app.userResponse(r.Context(), dbUser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading user", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
 		userWithAuth := newAuthResponse(loggedInUser, token, dbRefToken.Token)
 
+		app.metrics.Logins.Inc()
 		responseWithJSON(w, http.StatusOK, userWithAuth)
 	})
 }
@@ -251,7 +294,7 @@ func updateUserHandler(app *App) http.Handler {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&params); err != nil {
-			log.Printf("error decoding: %v", err)
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
@@ -264,17 +307,18 @@ func updateUserHandler(app *App) http.Handler {
 
 		token, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			log.Printf("error parsing: %v", err)
+			slog.WarnContext(r.Context(), "error parsing", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		validID, err := auth.ValidateJWT(token, app.config.JWTSecret)
 		if err != nil {
-			log.Printf("error checking token: %v", err)
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
 			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
 			return
 		}
+		logging.SetUserID(r.Context(), validID)
 
 		password, err := auth.HashPassword(params.Password)
 		if err != nil {
@@ -288,18 +332,23 @@ func updateUserHandler(app *App) http.Handler {
 			ID:             validID,
 		})
 		if err != nil {
-			log.Printf("error updating user: %v", err)
+			slog.ErrorContext(r.Context(), "error updating user", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		if err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword); err != nil {
-			log.Printf("error checking password: %v", err)
+			slog.WarnContext(r.Context(), "error checking password", "err", err)
 			responseWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 			return
 		}
 
-		updatedUser := newUserResponse(dbUser)
+		updatedUser, err := app.userResponse(r.Context(), dbUser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading user", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
 		responseWithJSON(w, http.StatusOK, updatedUser)
 	})
 }
@@ -308,18 +357,20 @@ func getUsersHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		dbUsers, err := app.db.GetUsers(r.Context())
 		if err != nil {
-			log.Printf("error retrieving chirps: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving users", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		users := make([]UserResponse, len(dbUsers))
-		for i, u := range dbUsers {
-			users[i] = newUserResponse(u)
+		users, err := app.publicUserResponses(r.Context(), dbUsers)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading users", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
 		}
 
 		responseWithJSON(w, http.StatusOK, struct {
-			Users []UserResponse `json:"users"`
+			Users []PublicUserResponse `json:"users"`
 		}{
 			Users: users,
 		})
@@ -330,20 +381,38 @@ func getUserByIDHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, err := uuid.Parse(r.PathValue("id"))
 		if err != nil {
-			log.Printf("error parsing chirp id: %v", err)
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		dbUser, err := app.db.GetUserByID(r.Context(), userID)
 		if err != nil {
-			log.Printf("error retrieving chirp: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		fetchedUser := newUserResponse(dbUser)
-		responseWithJSON(w, http.StatusOK, fetchedUser)
+		// only the user themselves gets the private fields
+		if dbUser.ID == app.viewerID(r) {
+			fetchedUser, err := app.userResponse(r.Context(), dbUser)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error loading user", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+			responseWithJSON(w, http.StatusOK, fetchedUser)
+			return
+		}
+
+		fetchedUsers, err := app.publicUserResponses(r.Context(), []database.User{dbUser})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading user", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, fetchedUsers[0])
 	})
 }
 
@@ -351,17 +420,40 @@ func deleteUserByID(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, err := uuid.Parse(r.PathValue("id"))
 		if err != nil {
-			log.Printf("error parsing user id: %v", err)
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		if _, err = app.db.DeleteUserByID(r.Context(), userID); err != nil {
-			log.Printf("error deleting user: %v", err)
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		// the row is only marked as deleted here, the purge worker removes
+		// it (and cascades to chirps and tokens) once the trash window ends
+		if _, err = qtx.SoftDeleteUserByID(r.Context(), userID); err != nil {
+			slog.ErrorContext(r.Context(), "error deleting user", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
+		if _, err = qtx.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+			slog.ErrorContext(r.Context(), "error revoking refresh tokens", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing user delete", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
 		responseWithNoContent(w, http.StatusNoContent)
 	})
 }
@@ -380,14 +472,14 @@ func upgradeUserHandler(app *App) http.Handler {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&params); err != nil {
-			log.Printf("error decoding params: %v", err)
+			slog.WarnContext(r.Context(), "error decoding params", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		key, err := auth.GetAPIKey(r.Header)
 		if err != nil {
-			log.Printf("error retrieving api key: %v", err)
+			slog.WarnContext(r.Context(), "error retrieving api key", "err", err)
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -398,6 +490,7 @@ func upgradeUserHandler(app *App) http.Handler {
 		}
 
 		if params.Event != "user.upgrade" {
+			app.metrics.WebhooksProcessed.WithLabelValues(metrics.WebhookIgnored).Inc()
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -407,38 +500,248 @@ func upgradeUserHandler(app *App) http.Handler {
 			ID:          params.Data.UserID,
 		})
 		if err != nil {
-			log.Printf("error upgrading user: %v", err)
+			slog.ErrorContext(r.Context(), "error upgrading user", "err", err)
+			app.metrics.WebhooksProcessed.WithLabelValues(metrics.WebhookFailed).Inc()
 			responseWithError(w, http.StatusNotFound, "User not found")
 			return
 		}
 
+		app.metrics.WebhooksProcessed.WithLabelValues(metrics.WebhookUpgraded).Inc()
 		w.WriteHeader(http.StatusNoContent)
 	})
 }
 
 type ChirpResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	EditedAt      *time.Time      `json:"edited_at"`
+	RevisionCount int32           `json:"revision_count"`
+	Body          string          `json:"body"`
+	UserID        uuid.UUID       `json:"user_id"`
+	PublishAt     *time.Time      `json:"publish_at,omitempty"`
+	Published     bool            `json:"published"`
+	Visibility    string          `json:"visibility"`
+	Media         []MediaResponse `json:"media"`
+	Poll          *PollResponse   `json:"poll,omitempty"`
+	QuoteOf       *uuid.UUID      `json:"quote_of,omitempty"`
+	Quote         *QuotedChirp    `json:"quote,omitempty"`
+	// Bookmarked is only set for the viewer's own bookmarks
+	Bookmarked *bool `json:"bookmarked,omitempty"`
+}
+
+// QuotedChirp is the compact form of a quoted chirp embedded in a quote.
+// When the quoted chirp can't be shown, only ID and Unavailable are set.
+type QuotedChirp struct {
+	ID          uuid.UUID  `json:"id"`
+	Unavailable bool       `json:"unavailable,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Body        string     `json:"body,omitempty"`
+}
+
+func newChirpResponse(chirp database.Chirp) ChirpResponse {
+	var quoteOf *uuid.UUID
+	if chirp.QuoteOf.Valid {
+		quoteOf = &chirp.QuoteOf.UUID
+	}
+
+	var editedAt, publishAt *time.Time
+	if chirp.EditedAt.Valid {
+		editedAt = &chirp.EditedAt.Time
+	}
+	if chirp.PublishAt.Valid {
+		publishAt = &chirp.PublishAt.Time
+	}
+
+	return ChirpResponse{
+		ID:            chirp.ID,
+		CreatedAt:     chirp.CreatedAt,
+		UpdatedAt:     chirp.UpdatedAt,
+		EditedAt:      editedAt,
+		RevisionCount: chirp.RevisionCount,
+		Body:          chirp.Body,
+		UserID:        chirp.UserID,
+		PublishAt:     publishAt,
+		Published:     chirp.Published,
+		Visibility:    chirp.Visibility,
+		Media:         []MediaResponse{},
+		QuoteOf:       quoteOf,
+	}
+}
+
+// chirpResponses converts chirps and loads the attachments and polls they
+// carry, as seen by viewer (uuid.Nil for anonymous requests).
+func (app *App) chirpResponses(ctx context.Context, chirps []database.Chirp,
+	viewer uuid.UUID) ([]ChirpResponse, error) {
+	resp := make([]ChirpResponse, len(chirps))
+	ids := make([]uuid.UUID, len(chirps))
+	index := make(map[uuid.UUID]int, len(chirps))
+	for i, c := range chirps {
+		resp[i] = newChirpResponse(c)
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+
+	if len(chirps) == 0 {
+		return resp, nil
+	}
+
+	attachments, err := app.db.GetAttachmentsByChirpIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, a := range attachments {
+		i := index[a.ChirpID.UUID]
+		resp[i].Media = append(resp[i].Media, app.newMediaResponse(a, now))
+	}
+
+	if err = app.loadPolls(ctx, resp, ids, index, viewer, now); err != nil {
+		return nil, err
+	}
+
+	if err = app.loadQuotes(ctx, resp, viewer); err != nil {
+		return nil, err
+	}
+
+	if err = app.loadBookmarks(ctx, resp, ids, index, viewer); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// loadQuotes inlines the chirps quoted by resp, or a tombstone for those
+// that were deleted or are not visible to viewer anymore.
+func (app *App) loadQuotes(ctx context.Context, resp []ChirpResponse, viewer uuid.UUID) error {
+	var quoteIDs []uuid.UUID
+	for _, c := range resp {
+		if c.QuoteOf != nil {
+			quoteIDs = append(quoteIDs, *c.QuoteOf)
+		}
+	}
+	if len(quoteIDs) == 0 {
+		return nil
+	}
+
+	quoted, err := app.db.GetChirpsByIDs(ctx, quoteIDs)
+	if err != nil {
+		return err
+	}
+
+	rel, err := app.loadRelations(ctx, viewer, chirpAuthors(quoted))
+	if err != nil {
+		return err
+	}
+
+	found := make(map[uuid.UUID]database.Chirp, len(quoted))
+	for _, q := range quoted {
+		if rel.canSee(q) {
+			found[q.ID] = q
+		}
+	}
+
+	for i, c := range resp {
+		if c.QuoteOf == nil {
+			continue
+		}
+
+		q, ok := found[*c.QuoteOf]
+		if !ok {
+			resp[i].Quote = &QuotedChirp{ID: *c.QuoteOf, Unavailable: true}
+			continue
+		}
+		resp[i].Quote = &QuotedChirp{
+			ID:        q.ID,
+			CreatedAt: &q.CreatedAt,
+			UserID:    &q.UserID,
+			Body:      q.Body,
+		}
+	}
+
+	return nil
+}
+
+func (app *App) chirpResponse(ctx context.Context, chirp database.Chirp,
+	viewer uuid.UUID) (ChirpResponse, error) {
+	resp, err := app.chirpResponses(ctx, []database.Chirp{chirp}, viewer)
+	if err != nil {
+		return ChirpResponse{}, err
+	}
+	return resp[0], nil
+}
+
+// chirpETag derives a strong entity tag from the time the chirp was last
+// updated. The revision count won't do, as scheduled chirps are edited
+// without keeping revisions.
+func chirpETag(chirp database.Chirp) string {
+	return fmt.Sprintf(`"%s-%d"`, chirp.ID, chirp.UpdatedAt.UnixMicro())
+}
+
+// matchETag reports whether an If-Match header value matches etag.
+func matchETag(header, etag string) bool {
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+type ChirpRevisionResponse struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
 	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
 }
 
-func newChirpResponse(chirp database.Chirp) ChirpResponse {
-	return ChirpResponse{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+func newChirpRevisionResponse(rev database.ChirpRevision) ChirpRevisionResponse {
+	return ChirpRevisionResponse{
+		ID:        rev.ID,
+		CreatedAt: rev.CreatedAt,
+		ChirpID:   rev.ChirpID,
+		Body:      rev.Body,
 	}
 }
 
+// authenticate returns the ID of the user the request's bearer token
+// belongs to.
+func (app *App) authenticate(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	id, err := auth.ValidateJWT(token, app.config.JWTSecret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	logging.SetUserID(r.Context(), id)
+	return id, nil
+}
+
+// viewerID is like authenticate for endpoints that also serve anonymous
+// requests. It returns uuid.Nil when there is no valid token.
+func (app *App) viewerID(r *http.Request) uuid.UUID {
+	id, err := app.authenticate(r)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
 func chirpHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		type CreateChirpRequest struct {
-			Body   string    `json:"body"`
-			UserID uuid.UUID `json:"user_id"`
+			Body          string       `json:"body"`
+			UserID        uuid.UUID    `json:"user_id"`
+			PublishAt     *time.Time   `json:"publish_at"`
+			AttachmentIDs []uuid.UUID  `json:"attachment_ids"`
+			Poll          *PollRequest `json:"poll"`
+			QuoteOf       *uuid.UUID   `json:"quote_of"`
+			Visibility    string       `json:"visibility"`
 		}
 		var params CreateChirpRequest
 		defer r.Body.Close()
@@ -446,62 +749,159 @@ func chirpHandler(app *App) http.Handler {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&params); err != nil {
-			log.Printf("error decoding: %v", err)
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		token, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			log.Printf("error retrieving token: %v", err)
+			slog.WarnContext(r.Context(), "error retrieving token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		validID, err := auth.ValidateJWT(token, app.config.JWTSecret)
 		if err != nil {
-			log.Printf("error validating token: %v", err)
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
 			responseWithError(w, http.StatusUnauthorized, "Something went wrong")
 			return
 		}
+		logging.SetUserID(r.Context(), validID)
 
 		if params.UserID != validID {
 			responseWithError(w, http.StatusForbidden, "Forbidden")
 			return
 		}
 
-		if len(params.Body) == 0 {
-			responseWithError(w, http.StatusBadRequest, "Empty request body")
+		str, problem := checkChirpBody(params.Body)
+		if problem != "" {
+			responseWithError(w, http.StatusBadRequest, problem)
 			return
 		}
 
-		if len(params.Body) > 140 {
-			responseWithError(w, http.StatusBadRequest, "Chirp is too long")
+		if params.PublishAt != nil && !params.PublishAt.After(time.Now()) {
+			responseWithError(w, http.StatusBadRequest, "publish_at must be in the future")
 			return
 		}
 
-		spl := strings.Split(params.Body, " ")
-		for i := range spl {
-			s := strings.ToLower(spl[i])
-			if s == "kerfuffle" || s == "sharbert" || s == "fornax" {
-				spl[i] = "****"
+		if params.Visibility == "" {
+			params.Visibility = visibilityPublic
+		}
+		if !validVisibility(params.Visibility) {
+			responseWithError(w, http.StatusBadRequest, "visibility must be public, unlisted or followers")
+			return
+		}
+
+		if len(params.AttachmentIDs) > maxAttachments {
+			responseWithError(w, http.StatusBadRequest, "Too many attachments")
+			return
+		}
+
+		if params.Poll != nil {
+			publishAt := time.Now()
+			if params.PublishAt != nil {
+				publishAt = *params.PublishAt
+			}
+			if problem := checkPoll(*params.Poll, publishAt); problem != "" {
+				responseWithError(w, http.StatusBadRequest, problem)
+				return
 			}
 		}
-		str := strings.Join(spl, " ")
 
-		dbChirp, err := app.db.CreateChirp(r.Context(),
-			database.CreateChirpParams{
-				Body:   str,
-				UserID: params.UserID,
-			},
-		)
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		var quoteOf uuid.NullUUID
+		if params.QuoteOf != nil {
+			quoted, err := app.getVisibleChirp(r.Context(), *params.QuoteOf, validID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error retrieving quoted chirp", "err", err)
+				responseWithError(w, http.StatusBadRequest, "Quoted chirp not found")
+				return
+			}
+			quoteOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		}
+
+		// publish_at has no time zone, so it's stored in UTC rather than
+		// losing the offset the client sent
+		var dbChirp database.Chirp
+		if params.PublishAt != nil {
+			dbChirp, err = qtx.CreateScheduledChirp(r.Context(),
+				database.CreateScheduledChirpParams{
+					Body:       str,
+					UserID:     params.UserID,
+					QuoteOf:    quoteOf,
+					Visibility: params.Visibility,
+					PublishAt:  sql.NullTime{Time: params.PublishAt.UTC(), Valid: true},
+				},
+			)
+		} else {
+			dbChirp, err = qtx.CreateChirp(r.Context(),
+				database.CreateChirpParams{
+					Body:       str,
+					UserID:     params.UserID,
+					QuoteOf:    quoteOf,
+					Visibility: params.Visibility,
+				},
+			)
+		}
 		if err != nil {
-			log.Printf("error generating chirps: %v", err)
+			slog.ErrorContext(r.Context(), "error creating chirp", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		createdChirp := newChirpResponse(dbChirp)
+		for i, attachmentID := range params.AttachmentIDs {
+			_, err = qtx.AttachToChirp(r.Context(), database.AttachToChirpParams{
+				ChirpID:  uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+				Position: int32(i),
+				ID:       attachmentID,
+				UserID:   validID,
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error attaching media", "attachment_id", attachmentID, "err", err)
+				responseWithError(w, http.StatusBadRequest, "Invalid attachment")
+				return
+			}
+		}
+
+		if params.Poll != nil {
+			if err = createPoll(r.Context(), qtx, dbChirp.ID, *params.Poll); err != nil {
+				slog.ErrorContext(r.Context(), "error creating poll", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+		}
+
+		// scheduled quotes notify when the publisher picks them up
+		if dbChirp.Published {
+			if err = notifyQuote(r.Context(), qtx, dbChirp); err != nil {
+				slog.ErrorContext(r.Context(), "error notifying quoted author", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		app.metrics.ChirpsCreated.Inc()
+
+		createdChirp, err := app.chirpResponse(r.Context(), dbChirp, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
 		responseWithJSON(w, http.StatusCreated, createdChirp)
 	})
 }
@@ -509,7 +909,8 @@ func chirpHandler(app *App) http.Handler {
 func updateChirpHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		type requestUpdateChirp struct {
-			Body string `json:"body"`
+			Body      string     `json:"body"`
+			PublishAt *time.Time `json:"publish_at"`
 		}
 		var params requestUpdateChirp
 		defer r.Body.Close()
@@ -517,51 +918,147 @@ func updateChirpHandler(app *App) http.Handler {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&params); err != nil {
-			log.Printf("error decoding: %v", err)
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		if len(params.Body) == 0 {
-			responseWithError(w, http.StatusBadRequest, "Empty request body")
+		str, problem := checkChirpBody(params.Body)
+		if problem != "" {
+			responseWithError(w, http.StatusBadRequest, problem)
 			return
 		}
 
-		if len(params.Body) > 140 {
-			responseWithError(w, http.StatusBadRequest, "Chirp is too long")
+		parsedID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		spl := strings.Split(params.Body, " ")
-		for i := range spl {
-			s := strings.ToLower(spl[i])
-			if s == "kerfuffle" || s == "sharbert" || s == "fornax" {
-				spl[i] = "****"
-			}
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
 		}
-		str := strings.Join(spl, " ")
 
-		parsedID, err := uuid.Parse(r.PathValue("id"))
+		tx, err := app.conn.BeginTx(r.Context(), nil)
 		if err != nil {
-			log.Printf("error parsing: %v", err)
-			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		// lock the row so concurrent edits are checked against the
+		// version they actually replace
+		current, err := qtx.GetChirpByIDForUpdate(r.Context(), parsedID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
 			return
 		}
 
-		dbChirp, err := app.db.UpdateChirp(
+		if current.UserID != validID {
+			responseWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		if match := r.Header.Get("If-Match"); match != "" && !matchETag(match, chirpETag(current)) {
+			responseWithError(w, http.StatusPreconditionFailed, "Chirp was modified")
+			return
+		}
+
+		// scheduled chirps have never been seen, so they are edited in
+		// place without keeping a revision
+		if !current.Published {
+			publishAt := current.PublishAt
+			if params.PublishAt != nil {
+				if !params.PublishAt.After(time.Now()) {
+					responseWithError(w, http.StatusBadRequest, "publish_at must be in the future")
+					return
+				}
+				publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+			}
+
+			dbChirp, err := qtx.UpdateScheduledChirp(r.Context(),
+				database.UpdateScheduledChirpParams{
+					Body:      str,
+					PublishAt: publishAt,
+					ID:        current.ID,
+				},
+			)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error updating scheduled chirp", "err", err)
+				responseWithError(w, http.StatusBadRequest, "Something went wrong")
+				return
+			}
+
+			if err = tx.Commit(); err != nil {
+				slog.ErrorContext(r.Context(), "error committing chirp update", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+
+			scheduledChirp, err := app.chirpResponse(r.Context(), dbChirp, validID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+
+			w.Header().Set("ETag", chirpETag(dbChirp))
+			responseWithJSON(w, http.StatusOK, scheduledChirp)
+			return
+		}
+
+		if params.PublishAt != nil {
+			responseWithError(w, http.StatusBadRequest, "Chirp is already published")
+			return
+		}
+
+		window := app.config.ChirpEditWindow
+		if window > 0 && time.Since(current.CreatedAt) > window {
+			responseWithError(w, http.StatusForbidden, "Edit window has expired")
+			return
+		}
+
+		// keep the version being replaced before overwriting it
+		if _, err = qtx.CreateChirpRevision(r.Context(), current.ID); err != nil {
+			slog.ErrorContext(r.Context(), "error saving chirp revision", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		dbChirp, err := qtx.UpdateChirp(
 			r.Context(),
 			database.UpdateChirpParams{
 				Body: str,
-				ID:   parsedID,
+				ID:   current.ID,
 			},
 		)
 		if err != nil {
-			log.Printf("error updating chrip: %v", err)
+			slog.ErrorContext(r.Context(), "error updating chirp", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		updatedChirp := newChirpResponse(dbChirp)
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing chirp update", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		updatedChirp, err := app.chirpResponse(r.Context(), dbChirp, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		w.Header().Set("ETag", chirpETag(dbChirp))
 		responseWithJSON(w, http.StatusOK, updatedChirp)
 	})
 }
@@ -570,14 +1067,33 @@ func getChirpsHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		dbChirps, err := app.db.GetChirps(r.Context())
 		if err != nil {
-			log.Printf("error retrieving chirps: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving chirps", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		chirps := make([]ChirpResponse, len(dbChirps))
-		for i, c := range dbChirps {
-			chirps[i] = newChirpResponse(c)
+		viewer := app.viewerID(r)
+		rel, err := app.loadRelations(r.Context(), viewer, chirpAuthors(dbChirps))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading relations", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		dbChirps = filterChirps(dbChirps, rel.inFeed)
+
+		words, err := app.loadWordFilter(r.Context(), viewer)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading muted words", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		dbChirps = words.filterTimeline(dbChirps)
+
+		chirps, err := app.chirpResponses(r.Context(), dbChirps, viewer)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
 		}
 
 		if sort := r.URL.Query().Get("sort"); sort != "desc" {
@@ -609,7 +1125,7 @@ func getChirpsHandler(app *App) http.Handler {
 		if id := r.URL.Query().Get("author_id"); id != "" {
 			authorID, err := uuid.Parse(id)
 			if err != nil {
-				log.Printf("error parsing author id: %v", err)
+				slog.WarnContext(r.Context(), "error parsing author id", "err", err)
 				responseWithError(w, http.StatusBadRequest, "Something went wrong")
 				return
 			}
@@ -644,53 +1160,184 @@ func getChirpsHandler(app *App) http.Handler {
 	})
 }
 
+func getScheduledChirpsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbChirps, err := app.db.GetScheduledChirps(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving scheduled chirps", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		chirps, err := app.chirpResponses(r.Context(), dbChirps, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Chrips []ChirpResponse `json:"chirps"`
+		}{
+			Chrips: chirps,
+		})
+	})
+}
+
 func getChripByIDHandler(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		chirpID, err := uuid.Parse(r.PathValue("id"))
 		if err != nil {
-			log.Printf("error parsing chirp id: %v", err)
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		dbChirp, err := app.db.GetChirpByID(r.Context(), chirpID)
+		// a chirp the viewer can't see looks the same as one that doesn't
+		// exist
+		viewer := app.viewerID(r)
+		dbChirp, err := app.getVisibleChirp(r.Context(), chirpID, viewer)
+		if errors.Is(err, errHidden) || errors.Is(err, sql.ErrNoRows) {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
 		if err != nil {
-			log.Printf("error retrieving chirp: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
-		fetchedChrip := newChirpResponse(dbChirp)
+		fetchedChrip, err := app.chirpResponse(r.Context(), dbChirp, viewer)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		w.Header().Set("ETag", chirpETag(dbChirp))
 		responseWithJSON(w, http.StatusOK, fetchedChrip)
 	})
 }
 
+func getChirpQuotesHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		dbChirps, err := app.db.GetQuotesOfChirp(r.Context(), uuid.NullUUID{UUID: chirpID, Valid: true})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving quotes", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		viewer := app.viewerID(r)
+		rel, err := app.loadRelations(r.Context(), viewer, chirpAuthors(dbChirps))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading relations", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// nothing quoting a blocked user's chirp is listed either
+		if _, err = app.getVisibleChirp(r.Context(), chirpID, viewer); errors.Is(err, errHidden) {
+			dbChirps = nil
+		}
+		dbChirps = filterChirps(dbChirps, rel.shows)
+
+		chirps, err := app.chirpResponses(r.Context(), dbChirps, viewer)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Chrips []ChirpResponse `json:"chirps"`
+		}{
+			Chrips: chirps,
+		})
+	})
+}
+
+func getChirpRevisionsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		if _, err = app.getVisibleChirp(r.Context(), chirpID, app.viewerID(r)); err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		dbRevisions, err := app.db.GetChirpRevisions(r.Context(), chirpID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirp revisions", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		revisions := make([]ChirpRevisionResponse, len(dbRevisions))
+		for i, rev := range dbRevisions {
+			revisions[i] = newChirpRevisionResponse(rev)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Revisions []ChirpRevisionResponse `json:"revisions"`
+		}{
+			Revisions: revisions,
+		})
+	})
+}
+
 func deleteChirpByID(app *App) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 		if err != nil {
-			log.Printf("error parsing user id: %v", err)
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		token, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			log.Printf("error retrieving token: %v", err)
+			slog.WarnContext(r.Context(), "error retrieving token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
 
 		validID, err := auth.ValidateJWT(token, app.config.JWTSecret)
 		if err != nil {
-			log.Printf("error validating token: %v", err)
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
 			responseWithError(w, http.StatusBadRequest, "Something went wrong")
 			return
 		}
+		logging.SetUserID(r.Context(), validID)
 
-		dbChirp, err := app.db.GetChirpByID(r.Context(), chirpID)
+		dbChirp, err := app.db.GetChirpByIDForAuthor(r.Context(),
+			database.GetChirpByIDForAuthorParams{
+				ID:     chirpID,
+				UserID: validID,
+			},
+		)
 		if err != nil {
-			log.Printf("error retrieving user: %v", err)
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
 			responseWithError(w, http.StatusNotFound, "Not found")
 			return
 		}
@@ -700,20 +1347,86 @@ func deleteChirpByID(app *App) http.Handler {
 			return
 		}
 
-		if _, err = app.db.DeleteChirpByID(r.Context(), dbChirp.ID); err != nil {
-			log.Printf("error deleting user: %v", err)
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		if _, err = qtx.SoftDeleteChirpByID(r.Context(), dbChirp.ID); err != nil {
+			slog.ErrorContext(r.Context(), "error deleting chirp", "err", err)
 			responseWithError(w, http.StatusNotFound, "Not found")
 			return
 		}
 
+		// a deleted chirp stops counting against the author's pin limit,
+		// restoring it does not pin it again
+		if err = qtx.DeleteChirpPins(r.Context(), dbChirp.ID); err != nil {
+			slog.ErrorContext(r.Context(), "error unpinning chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing chirp delete", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
 		responseWithNoContent(w, http.StatusNoContent)
 	})
 }
 
+func restoreChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		// only chirps deleted by the caller within the trash window can
+		// be restored
+		dbChirp, err := app.db.RestoreChirpByID(r.Context(), database.RestoreChirpByIDParams{
+			ID:     chirpID,
+			UserID: validID,
+			DeletedAt: sql.NullTime{
+				Time:  time.Now().Add(-app.config.TrashRetention),
+				Valid: true,
+			},
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error restoring chirp", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		restoredChirp, err := app.chirpResponse(r.Context(), dbChirp, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, restoredChirp)
+	})
+}
+
 func responseWithJSON(w http.ResponseWriter, code int, payload any) {
 	resp, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("error marshaling: %v", err)
+		slog.Error("error marshaling response", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Internal server error"}`))
 		return
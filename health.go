@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prchop/chirpysrv/sql/schema"
+)
+
+// readyTimeout bounds the database checks of a readiness probe.
+const readyTimeout = 2 * time.Second
+
+// workerStaleAfter is how many intervals a worker may miss before it
+// counts as stuck.
+const workerStaleAfter = 3
+
+const (
+	checkOK   = "ok"
+	checkFail = "fail"
+)
+
+// WorkerStatus is what the readiness probe reports about a background
+// worker.
+type WorkerStatus struct {
+	Running   bool       `json:"running"`
+	LastRun   *time.Time `json:"last_run"`
+	LastError string     `json:"last_error,omitempty"`
+	interval  time.Duration
+}
+
+// workerRegistry tracks the background workers for the readiness probe.
+type workerRegistry struct {
+	mu      sync.Mutex
+	workers map[string]*WorkerStatus
+}
+
+func newWorkerRegistry() *workerRegistry {
+	return &workerRegistry{workers: map[string]*WorkerStatus{}}
+}
+
+// start marks the worker name as running every interval.
+func (reg *workerRegistry) start(name string, interval time.Duration) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.workers[name] = &WorkerStatus{Running: true, interval: interval}
+}
+
+func (reg *workerRegistry) stop(name string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if w, ok := reg.workers[name]; ok {
+		w.Running = false
+	}
+}
+
+// ran records that a run of the worker name ended with err.
+func (reg *workerRegistry) ran(name string, err error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	w, ok := reg.workers[name]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	w.LastRun = &now
+	w.LastError = ""
+	if err != nil {
+		w.LastError = err.Error()
+	}
+}
+
+// check returns a copy of every worker's status, and an error when one
+// stopped or missed too many runs.
+func (reg *workerRegistry) check(now time.Time) (map[string]WorkerStatus, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	var problem error
+	workers := make(map[string]WorkerStatus, len(reg.workers))
+	for name, w := range reg.workers {
+		workers[name] = *w
+		switch {
+		case !w.Running:
+			problem = fmt.Errorf("%s is not running", name)
+		case w.LastRun != nil && now.Sub(*w.LastRun) > workerStaleAfter*w.interval:
+			problem = fmt.Errorf("%s hasn't run since %s", name, w.LastRun.Format(time.RFC3339))
+		}
+	}
+	return workers, problem
+}
+
+type CheckResult struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func newCheckResult(err error, details any) CheckResult {
+	if err != nil {
+		return CheckResult{Status: checkFail, Error: err.Error(), Details: details}
+	}
+	return CheckResult{Status: checkOK, Details: details}
+}
+
+// currentSchemaVersion returns the newest migration goose applied. Down
+// migrations are recorded as unapplied rows, so only the latest row of
+// each version counts.
+func (app *App) currentSchemaVersion(ctx context.Context) (int64, error) {
+	var v int64
+	err := app.conn.QueryRowContext(ctx, `
+SELECT COALESCE(MAX(version_id), 0) FROM (
+  SELECT DISTINCT ON (version_id) version_id, is_applied
+  FROM goose_db_version
+  ORDER BY version_id, id DESC
+) v
+WHERE is_applied`).Scan(&v)
+	return v, err
+}
+
+func (app *App) checkMigrations(ctx context.Context) CheckResult {
+	expected, err := schema.Version()
+	if err != nil {
+		return newCheckResult(err, nil)
+	}
+
+	current, err := app.currentSchemaVersion(ctx)
+	details := map[string]int64{"current": current, "expected": expected}
+	if err != nil {
+		return newCheckResult(err, details)
+	}
+	// a newer database is fine, it happens while a release rolls out
+	if current < expected {
+		return newCheckResult(fmt.Errorf("database is at %d, want %d", current, expected), details)
+	}
+	return newCheckResult(nil, details)
+}
+
+// livenessHandler only reports that the process serves requests, so a
+// database outage doesn't get the instance restarted.
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+func readinessHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		resp := ReadinessResponse{
+			Status: checkOK,
+			Checks: map[string]CheckResult{},
+		}
+
+		var shuttingDown error
+		if app.shuttingDown.Load() {
+			shuttingDown = fmt.Errorf("server is shutting down")
+		}
+		resp.Checks["shutdown"] = newCheckResult(shuttingDown, nil)
+
+		start := time.Now()
+		err := app.conn.PingContext(ctx)
+		resp.Checks["database"] = newCheckResult(err, map[string]string{
+			"latency": time.Since(start).String(),
+		})
+
+		if err == nil {
+			resp.Checks["migrations"] = app.checkMigrations(ctx)
+		} else {
+			resp.Checks["migrations"] = newCheckResult(fmt.Errorf("database unavailable"), nil)
+		}
+
+		workers, err := app.workers.check(time.Now())
+		resp.Checks["workers"] = newCheckResult(err, workers)
+
+		code := http.StatusOK
+		for name, c := range resp.Checks {
+			if c.Status != checkOK {
+				slog.WarnContext(r.Context(), "readiness check failed", "check", name, "err", c.Error)
+				resp.Status = checkFail
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		responseWithJSON(w, code, resp)
+	})
+}
@@ -7,23 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, visibility)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	QuoteOf    uuid.NullUUID
+	Visibility string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.QuoteOf,
+		arg.Visibility,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -31,6 +41,53 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const createScheduledChirp = `-- name: CreateScheduledChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, visibility, publish_at, published)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, FALSE)
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
+`
+
+type CreateScheduledChirpParams struct {
+	Body       string
+	UserID     uuid.UUID
+	QuoteOf    uuid.NullUUID
+	Visibility string
+	PublishAt  sql.NullTime
+}
+
+func (q *Queries) CreateScheduledChirp(ctx context.Context, arg CreateScheduledChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledChirp,
+		arg.Body,
+		arg.UserID,
+		arg.QuoteOf,
+		arg.Visibility,
+		arg.PublishAt,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
 	)
 	return i, err
 }
@@ -44,14 +101,67 @@ func (q *Queries) DeleteAllChirps(ctx context.Context) error {
 	return err
 }
 
-const deleteChirpByID = `-- name: DeleteChirpByID :one
-DELETE FROM chirps
+const exportChirps = `-- name: ExportChirps :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+  AND (created_at, id) > ($1::timestamp, $2::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $3
+`
+
+type ExportChirpsParams struct {
+	AfterTime time.Time
+	AfterID   uuid.UUID
+	PageSize  int32
+}
+
+func (q *Queries) ExportChirps(ctx context.Context, arg ExportChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, exportChirps, arg.AfterTime, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
 WHERE id = $1
-RETURNING id, created_at, updated_at, body, user_id
+  AND published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
 `
 
-func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, deleteChirpByID, id)
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -59,17 +169,58 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (Chirp, err
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
 	)
 	return i, err
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+const getChirpByIDForAuthor = `-- name: GetChirpByIDForAuthor :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
 WHERE id = $1
+  AND (published OR user_id = $2)
+  AND deleted_at IS NULL
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+type GetChirpByIDForAuthorParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetChirpByIDForAuthor(ctx context.Context, arg GetChirpByIDForAuthorParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDForAuthor, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getChirpByIDForUpdate = `-- name: GetChirpByIDForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE id = $1
+  AND deleted_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) GetChirpByIDForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDForUpdate, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -77,12 +228,22 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
 ORDER BY user_id ASC
 `
 
@@ -101,6 +262,146 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE id = ANY($1::uuid[])
+  AND published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQuotesOfChirp = `-- name: GetQuotesOfChirp :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE quote_of = $1
+  AND published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetQuotesOfChirp(ctx context.Context, quoteOf uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getQuotesOfChirp, quoteOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getScheduledChirps = `-- name: GetScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE user_id = $1
+  AND NOT published
+  AND deleted_at IS NULL
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -115,10 +416,285 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
+const getUserChirps = `-- name: GetUserChirps :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility FROM chirps
+WHERE user_id = $1
+  AND (created_at, id) < ($2::timestamp, $3::uuid)
+  AND published
+  AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetUserChirpsParams struct {
+	UserID     uuid.UUID
+	BeforeTime time.Time
+	BeforeID   uuid.UUID
+	PageSize   int32
+}
+
+// Keyset pagination, newest first. Pass the created_at and id of the
+// last row of the previous page.
+func (q *Queries) GetUserChirps(ctx context.Context, arg GetUserChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUserChirps,
+		arg.UserID,
+		arg.BeforeTime,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importChirp = `-- name: ImportChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, edited_at, publish_at, published, quote_of, visibility)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (id) DO NOTHING
+`
+
+type ImportChirpParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	EditedAt   sql.NullTime
+	PublishAt  sql.NullTime
+	Published  bool
+	QuoteOf    uuid.NullUUID
+	Visibility string
+}
+
+func (q *Queries) ImportChirp(ctx context.Context, arg ImportChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, importChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.EditedAt,
+		arg.PublishAt,
+		arg.Published,
+		arg.QuoteOf,
+		arg.Visibility,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const importChirpOverwrite = `-- name: ImportChirpOverwrite :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, edited_at, publish_at, published, quote_of, visibility)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (id) DO UPDATE SET
+  created_at = EXCLUDED.created_at,
+  updated_at = EXCLUDED.updated_at,
+  body = EXCLUDED.body,
+  user_id = EXCLUDED.user_id,
+  edited_at = EXCLUDED.edited_at,
+  publish_at = EXCLUDED.publish_at,
+  published = EXCLUDED.published,
+  quote_of = EXCLUDED.quote_of,
+  visibility = EXCLUDED.visibility,
+  deleted_at = NULL
+RETURNING (xmax = 0) AS inserted
+`
+
+type ImportChirpOverwriteParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	EditedAt   sql.NullTime
+	PublishAt  sql.NullTime
+	Published  bool
+	QuoteOf    uuid.NullUUID
+	Visibility string
+}
+
+// Returns whether the chirp was inserted rather than updated.
+func (q *Queries) ImportChirpOverwrite(ctx context.Context, arg ImportChirpOverwriteParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, importChirpOverwrite,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.EditedAt,
+		arg.PublishAt,
+		arg.Published,
+		arg.QuoteOf,
+		arg.Visibility,
+	)
+	var inserted bool
+	err := row.Scan(&inserted)
+	return inserted, err
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps SET (created_at, updated_at, published) = (publish_at, NOW(), TRUE)
+WHERE id IN (
+  SELECT id FROM chirps
+  WHERE NOT published
+    AND publish_at <= NOW()
+    AND deleted_at IS NULL
+  ORDER BY publish_at ASC
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
+`
+
+// Rows locked by another instance are skipped, so several publishers can
+// run at once without publishing the same chirp twice.
+func (q *Queries) PublishDueChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.PublishAt,
+			&i.Published,
+			&i.QuoteOf,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirpByID = `-- name: RestoreChirpByID :one
+UPDATE chirps SET (updated_at, deleted_at) = (NOW(), NULL)
+WHERE id = $1
+  AND user_id = $2
+  AND deleted_at > $3
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
+`
+
+type RestoreChirpByIDParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) RestoreChirpByID(ctx context.Context, arg RestoreChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirpByID, arg.ID, arg.UserID, arg.DeletedAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const softDeleteChirpByID = `-- name: SoftDeleteChirpByID :one
+UPDATE chirps SET deleted_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
+`
+
+func (q *Queries) SoftDeleteChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
+	)
+	return i, err
+}
+
 const updateChirp = `-- name: UpdateChirp :one
-UPDATE chirps SET (updated_at, body) = (NOW(), $1)
+UPDATE chirps SET (updated_at, edited_at, revision_count, body) =
+  (NOW(), NOW(), revision_count + 1, $1)
 WHERE id = $2
-RETURNING id, created_at, updated_at, body, user_id
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
 `
 
 type UpdateChirpParams struct {
@@ -135,6 +711,47 @@ func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const updateScheduledChirp = `-- name: UpdateScheduledChirp :one
+UPDATE chirps SET (updated_at, body, publish_at) = (NOW(), $1, $2)
+WHERE id = $3
+  AND NOT published
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, edited_at, revision_count, deleted_at, publish_at, published, quote_of, visibility
+`
+
+type UpdateScheduledChirpParams struct {
+	Body      string
+	PublishAt sql.NullTime
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateScheduledChirp(ctx context.Context, arg UpdateScheduledChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledChirp, arg.Body, arg.PublishAt, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.PublishAt,
+		&i.Published,
+		&i.QuoteOf,
+		&i.Visibility,
 	)
 	return i, err
 }
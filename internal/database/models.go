@@ -11,12 +11,161 @@ import (
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	Width        int32
+	Height       int32
+	BlobKey      string
+	ThumbnailKey string
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.UUID
+	EditedAt      sql.NullTime
+	RevisionCount int32
+	DeletedAt     sql.NullTime
+	PublishAt     sql.NullTime
+	Published     bool
+	QuoteOf       uuid.NullUUID
+	Visibility    string
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	Body      string
+}
+
+type Collection struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+type CollectionChirp struct {
+	CollectionID uuid.UUID
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CreatedAt    time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DirectKey sql.NullString
+	Name      string
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Body      string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type FollowRequest struct {
+	RequesterID uuid.UUID
+	TargetID    uuid.UUID
+	CreatedAt   time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type MutedWord struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Phrase    string
+	Scope     string
+	ExpiresAt sql.NullTime
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ChirpID     uuid.UUID
+	CreatedAt   time.Time
+	ClosesAt    time.Time
+	HideResults bool
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	ChirpID  uuid.UUID
+	Position int32
+	Label    string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
 	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -35,4 +184,13 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	DeletedAt      sql.NullTime
+	Bio            string
+	Location       string
+	Website        string
+	AvatarID       uuid.NullUUID
+	BannerID       uuid.NullUUID
+	DmPolicy       string
+	Protected      bool
+	IsAdmin        bool
 }
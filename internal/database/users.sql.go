@@ -7,14 +7,17 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin
 `
 
 type CreateUserParams struct {
@@ -32,6 +35,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -45,14 +57,96 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
-const deleteUserByID = `-- name: DeleteUserByID :one
-DELETE FROM users
+const exportUsers = `-- name: ExportUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
+WHERE deleted_at IS NULL
+  AND (created_at, id) > ($1::timestamp, $2::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $3
+`
+
+type ExportUsersParams struct {
+	AfterTime time.Time
+	AfterID   uuid.UUID
+	PageSize  int32
+}
+
+func (q *Queries) ExportUsers(ctx context.Context, arg ExportUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, exportUsers, arg.AfterTime, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.DeletedAt,
+			&i.Bio,
+			&i.Location,
+			&i.Website,
+			&i.AvatarID,
+			&i.BannerID,
+			&i.DmPolicy,
+			&i.Protected,
+			&i.IsAdmin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
+WHERE email = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  AND deleted_at IS NULL
 `
 
-func (q *Queries) DeleteUserByID(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, deleteUserByID, id)
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -61,17 +155,28 @@ func (q *Queries) DeleteUserByID(ctx context.Context, id uuid.UUID) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
-WHERE email = $1
+const getUserByIDForShare = `-- name: GetUserByIDForShare :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+FOR SHARE
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+func (q *Queries) GetUserByIDForShare(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIDForShare, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -80,17 +185,28 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
-const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+const getUserByIDForUpdate = `-- name: GetUserByIDForUpdate :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
 WHERE id = $1
+  AND deleted_at IS NULL
+FOR UPDATE
 `
 
-func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByID, id)
+func (q *Queries) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIDForUpdate, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -99,12 +215,21 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUserByRefreshToken = `-- name: GetUserByRefreshToken :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
 WHERE id = (
   SELECT user_id
   FROM refresh_tokens
@@ -112,6 +237,7 @@ WHERE id = (
     AND expires_at > NOW()
     AND revoked_at IS NULL
 )
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (User, error) {
@@ -124,12 +250,22 @@ func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (User
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin FROM users
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -149,6 +285,15 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.Email,
 			&i.HashedPassword,
 			&i.IsChirpyRed,
+			&i.DeletedAt,
+			&i.Bio,
+			&i.Location,
+			&i.Website,
+			&i.AvatarID,
+			&i.BannerID,
+			&i.DmPolicy,
+			&i.Protected,
+			&i.IsAdmin,
 		); err != nil {
 			return nil, err
 		}
@@ -163,10 +308,221 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const importUser = `-- name: ImportUser :execrows
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, bio, location, website, dm_policy, protected)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO NOTHING
+`
+
+type ImportUserParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Email          string
+	HashedPassword string
+	IsChirpyRed    bool
+	IsAdmin        bool
+	Bio            string
+	Location       string
+	Website        string
+	DmPolicy       string
+	Protected      bool
+}
+
+func (q *Queries) ImportUser(ctx context.Context, arg ImportUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, importUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Email,
+		arg.HashedPassword,
+		arg.IsChirpyRed,
+		arg.IsAdmin,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.DmPolicy,
+		arg.Protected,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const importUserOverwrite = `-- name: ImportUserOverwrite :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, bio, location, website, dm_policy, protected)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO UPDATE SET
+  created_at = EXCLUDED.created_at,
+  updated_at = EXCLUDED.updated_at,
+  email = EXCLUDED.email,
+  hashed_password = COALESCE(NULLIF(EXCLUDED.hashed_password, ''), users.hashed_password),
+  is_chirpy_red = EXCLUDED.is_chirpy_red,
+  is_admin = EXCLUDED.is_admin,
+  bio = EXCLUDED.bio,
+  location = EXCLUDED.location,
+  website = EXCLUDED.website,
+  dm_policy = EXCLUDED.dm_policy,
+  protected = EXCLUDED.protected,
+  deleted_at = NULL
+RETURNING (xmax = 0) AS inserted
+`
+
+type ImportUserOverwriteParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Email          string
+	HashedPassword string
+	IsChirpyRed    bool
+	IsAdmin        bool
+	Bio            string
+	Location       string
+	Website        string
+	DmPolicy       string
+	Protected      bool
+}
+
+// An empty hashed_password keeps the current password. Returns whether
+// the user was inserted rather than updated.
+func (q *Queries) ImportUserOverwrite(ctx context.Context, arg ImportUserOverwriteParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, importUserOverwrite,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Email,
+		arg.HashedPassword,
+		arg.IsChirpyRed,
+		arg.IsAdmin,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.DmPolicy,
+		arg.Protected,
+	)
+	var inserted bool
+	err := row.Scan(&inserted)
+	return inserted, err
+}
+
+const lockUsers = `-- name: LockUsers :many
+SELECT id FROM users
+WHERE id = ANY($1::uuid[])
+  AND deleted_at IS NULL
+ORDER BY id
+FOR NO KEY UPDATE
+`
+
+// Locks the users in id order, so two transactions locking the same
+// users can't deadlock. The lock waits for FOR SHARE but not for the
+// key share locks of foreign keys.
+func (q *Queries) LockUsers(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, lockUsers, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserAdmin = `-- name: SetUserAdmin :one
+UPDATE users SET (updated_at, is_admin) = (NOW(), $1)
+WHERE id = $2
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin
+`
+
+type SetUserAdminParams struct {
+	IsAdmin bool
+	ID      uuid.UUID
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAdmin, arg.IsAdmin, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const softDeleteUserByID = `-- name: SoftDeleteUserByID :one
+UPDATE users SET deleted_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin
+`
+
+func (q *Queries) SoftDeleteUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users SET (updated_at, email, hashed_password) = (NOW(), $1, $2)
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin
 `
 
 type UpdateUserParams struct {
@@ -185,6 +541,65 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users SET (updated_at, bio, location, website, avatar_id, banner_id, dm_policy, protected) = (NOW(), $1, $2, $3, $4, $5, $6, $7)
+WHERE id = $8
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin
+`
+
+type UpdateUserProfileParams struct {
+	Bio       string
+	Location  string
+	Website   string
+	AvatarID  uuid.NullUUID
+	BannerID  uuid.NullUUID
+	DmPolicy  string
+	Protected bool
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.AvatarID,
+		arg.BannerID,
+		arg.DmPolicy,
+		arg.Protected,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -192,7 +607,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 const upgradeUser = `-- name: UpgradeUser :one
 UPDATE users SET (updated_at, is_chirpy_red) = (NOW(), $1)
 WHERE id = $2
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, bio, location, website, avatar_id, banner_id, dm_policy, protected, is_admin
 `
 
 type UpgradeUserParams struct {
@@ -210,6 +626,15 @@ func (q *Queries) UpgradeUser(ctx context.Context, arg UpgradeUserParams) (User,
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarID,
+		&i.BannerID,
+		&i.DmPolicy,
+		&i.Protected,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -0,0 +1,177 @@
+// Package logging configures slog for the server and ties log lines to
+// the request they were written for.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in and out of the server.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps request IDs taken from clients.
+const maxRequestIDLength = 128
+
+// New returns a logger writing to w. level is one of debug, info, warn
+// or error and format is json or text. Records logged with a request
+// context get its request_id and user_id attached.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	return slog.New(contextHandler{h}), nil
+}
+
+// requestInfo is what the middleware knows about a request. UserID is
+// filled in by the handler once it authenticated the caller.
+type requestInfo struct {
+	id     string
+	userID string
+}
+
+type requestKey struct{}
+
+func infoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestKey{}).(*requestInfo)
+	return info
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside
+// of a request.
+func RequestID(ctx context.Context) string {
+	if info := infoFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user of the request ctx belongs
+// to, so later log lines and the access log name them.
+func SetUserID(ctx context.Context, id uuid.UUID) {
+	if info := infoFrom(ctx); info != nil {
+		info.userID = id.String()
+	}
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := infoFrom(ctx); info != nil {
+		r.AddAttrs(slog.String("request_id", info.id))
+		if info.userID != "" {
+			r.AddAttrs(slog.String("user_id", info.userID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// validRequestID reports whether a client supplied ID is safe to log and
+// echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool {
+		return r < 0x21 || r > 0x7e
+	})
+}
+
+// responseRecorder remembers the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Middleware assigns every request an ID, or keeps the one the client
+// sent in X-Request-ID, and writes one access log line per request once
+// it is served. It has to wrap the ServeMux so the route pattern is
+// known.
+func Middleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestKey{}, &requestInfo{id: id})
+		inner := r.WithContext(ctx)
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, inner)
+		// the mux sets the pattern on the copy, outer middleware needs it
+		r.Pattern = inner.Pattern
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", rec.bytes),
+		)
+	})
+}
@@ -0,0 +1,167 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/logging"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{name: "json", level: "info", format: "json"},
+		{name: "text", level: "debug", format: "text"},
+		{name: "upper case level", level: "WARN", format: "json"},
+		{name: "unknown level", level: "loud", format: "json", wantErr: true},
+		{name: "unknown format", level: "info", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := logging.New(io.Discard, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got err: %v, wantErr: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// serve runs one request through the middleware and returns the
+// response and the decoded log lines.
+func serve(t *testing.T, req *http.Request, h http.HandlerFunc) (*httptest.ResponseRecorder, []map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /chirps/{id}", h)
+
+	w := httptest.NewRecorder()
+	logging.Middleware(logger, mux).ServeHTTP(w, req)
+
+	var lines []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return w, lines
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "propagated", header: "abc-123", keep: true},
+		{name: "missing", header: ""},
+		{name: "too long", header: strings.Repeat("a", 200)},
+		{name: "control characters", header: "abc\x01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/chirps/1", nil)
+			if tt.header != "" {
+				req.Header.Set(logging.RequestIDHeader, tt.header)
+			}
+
+			var seen string
+			w, _ := serve(t, req, func(w http.ResponseWriter, r *http.Request) {
+				seen = logging.RequestID(r.Context())
+			})
+
+			got := w.Header().Get(logging.RequestIDHeader)
+			if got != seen {
+				t.Errorf("handler saw %q, response has %q", seen, got)
+			}
+			if tt.keep && got != tt.header {
+				t.Errorf("got: %q, want: %q", got, tt.header)
+			}
+			if !tt.keep && (got == "" || got == tt.header) {
+				t.Errorf("got: %q, want a generated ID", got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAccessLog(t *testing.T) {
+	userID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/chirps/1", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-1")
+
+	_, lines := serve(t, req, func(w http.ResponseWriter, r *http.Request) {
+		logging.SetUserID(r.Context(), userID)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short"))
+	})
+
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1", len(lines))
+	}
+
+	want := map[string]any{
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "GET /chirps/{id}",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(5),
+		"request_id": "req-1",
+		"user_id":    userID.String(),
+	}
+	for k, v := range want {
+		if lines[0][k] != v {
+			t.Errorf("%s: got: %v, want: %v", k, lines[0][k], v)
+		}
+	}
+	if _, ok := lines[0]["latency"]; !ok {
+		t.Error("latency is missing")
+	}
+}
+
+func TestHandlerAddsRequestAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/chirps/1", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-2")
+
+	h := logging.Middleware(slog.New(slog.DiscardHandler), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.With("component", "test").ErrorContext(r.Context(), "error retrieving chirp")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["request_id"] != "req-2" {
+		t.Errorf("got: %v, want: req-2", line["request_id"])
+	}
+	if _, ok := line["user_id"]; ok {
+		t.Errorf("got user_id %v for an anonymous request", line["user_id"])
+	}
+}
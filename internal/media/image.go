@@ -0,0 +1,143 @@
+// Package media prepares uploaded images for publishing and signs the URLs
+// they are served from.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrTooLarge        = errors.New("image is too large")
+	ErrUnsupportedType = errors.New("unsupported image type")
+)
+
+const (
+	// maxPixels guards against decompression bombs, small files that
+	// decode into huge images. Decoding, orienting and scaling hold a
+	// few copies of the pixels, so an image at the limit takes a couple
+	// hundred MB.
+	maxPixels = 16_000_000
+	// maxProcessing is how many images are decoded at once. Further
+	// uploads wait for their turn.
+	maxProcessing = 2
+
+	thumbnailSize = 320
+	jpegQuality   = 90
+)
+
+// processing holds a slot for every image being decoded.
+var processing = make(chan struct{}, maxProcessing)
+
+// Image is an upload that has been checked, stripped of metadata and
+// re-encoded, together with its thumbnail.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+	Thumbnail   []byte
+}
+
+// Process reads an uploaded JPEG or PNG of at most maxBytes and returns
+// it re-encoded. Re-encoding drops EXIF and any other metadata, so the
+// EXIF orientation is applied to the pixels first.
+func Process(r io.Reader, maxBytes int64) (Image, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return Image{}, err
+	}
+	if int64(len(raw)) > maxBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(raw)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return Image{}, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return Image{}, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	processing <- struct{}{}
+	defer func() { <-processing }()
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return Image{}, err
+	}
+
+	if contentType == "image/jpeg" {
+		src = orient(src, jpegOrientation(raw))
+	}
+
+	data, err := encode(src, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	thumb, err := encode(thumbnail(src, thumbnailSize), contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	ext := ".png"
+	if contentType == "image/jpeg" {
+		ext = ".jpg"
+	}
+
+	b := src.Bounds()
+	return Image{
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       b.Dx(),
+		Height:      b.Dy(),
+		Data:        data,
+		Thumbnail:   thumb,
+	}, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// thumbnail scales img down so its longest side is at most size pixels.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,136 @@
+package media_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prchop/chirpysrv/internal/media"
+)
+
+// jpegWithOrientation encodes a w×h JPEG and adds an EXIF segment that
+// carries the given orientation.
+func jpegWithOrientation(t *testing.T, w, h int, orientation byte) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big endian header, IFD0 at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // orientation, SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	size := len(segment) + 2
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(size >> 8), byte(size)}
+	out = append(out, segment...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func TestProcessStripsExifAndAppliesOrientation(t *testing.T) {
+	raw := jpegWithOrientation(t, 800, 400, 6)
+
+	img, err := media.Process(bytes.NewReader(raw), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Error("processed image still carries EXIF data")
+	}
+
+	// rotated 90 degrees, so width and height swap
+	if img.Width != 400 || img.Height != 800 {
+		t.Errorf("got: %dx%d, want: 400x800", img.Width, img.Height)
+	}
+
+	thumb, _, err := image.DecodeConfig(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Width != 160 || thumb.Height != 320 {
+		t.Errorf("got thumbnail: %dx%d, want: 160x320", thumb.Width, thumb.Height)
+	}
+
+	if img.ContentType != "image/jpeg" || img.Ext != ".jpg" {
+		t.Errorf("got: %s %s, want: image/jpeg .jpg", img.ContentType, img.Ext)
+	}
+}
+
+func TestProcessRejectsInvalidUploads(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		maxBytes int64
+		wantErr  error
+	}{
+		{"not an image", []byte("hello chirpy"), 1 << 20, media.ErrUnsupportedType},
+		{"too large", bytes.Repeat([]byte{0}, 2048), 1024, media.ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := media.Process(bytes.NewReader(tt.data), tt.maxBytes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got: %v, want: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignerURL(t *testing.T) {
+	signer := media.NewSigner("secret", time.Hour)
+	now := time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)
+
+	raw := signer.URL("photo.jpg", now)
+	if raw != signer.URL("photo.jpg", now.Add(10*time.Minute)) {
+		t.Error("want the same URL within one period so it can be cached")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.TrimPrefix(u.Path, "/media/")
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if _, err = signer.Verify(key, expires, signature, now); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		signature string
+		now       time.Time
+	}{
+		{"other key", "other.jpg", signature, now},
+		{"bad signature", key, "deadbeef", now},
+		{"expired", key, signature, now.Add(3 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.key, expires, tt.signature, tt.now)
+			if !errors.Is(err, media.ErrInvalidSignature) {
+				t.Errorf("got: %v, want: %v", err, media.ErrInvalidSignature)
+			}
+		})
+	}
+}
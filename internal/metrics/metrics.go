@@ -0,0 +1,158 @@
+// Package metrics exports the server's metrics in the Prometheus
+// exposition format.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chirpy"
+
+// Webhook results, used as the result label of WebhooksProcessed.
+const (
+	WebhookUpgraded = "upgraded"
+	WebhookIgnored  = "ignored"
+	WebhookFailed   = "failed"
+)
+
+// Metrics holds the collectors of one server. The business counters are
+// exported for handlers to increment.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+
+	ChirpsCreated     prometheus.Counter
+	Logins            prometheus.Counter
+	FailedLogins      prometheus.Counter
+	WebhooksProcessed *prometheus.CounterVec
+}
+
+// New returns metrics registered on their own registry, along with Go
+// runtime, process and connection pool stats of db.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route pattern and status.",
+		}, []string{"route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served.",
+		}),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created, including scheduled chirps and published drafts.",
+		}),
+		Logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Successful logins.",
+		}),
+		FailedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Logins rejected for a wrong email or password.",
+		}),
+		WebhooksProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhooks_processed_total",
+			Help:      "Authenticated payment webhooks, by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.ChirpsCreated,
+		m.Logins,
+		m.FailedLogins,
+		m.WebhooksProcessed,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+
+	// results show up at zero before the first webhook arrives
+	for _, result := range []string{WebhookUpgraded, WebhookIgnored, WebhookFailed} {
+		m.WebhooksProcessed.WithLabelValues(result)
+	}
+
+	return m
+}
+
+// Handler serves the metrics for scraping.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// statusRecorder remembers the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Middleware counts and times requests. It has to wrap the ServeMux so
+// the route pattern is known, unmatched requests share one label.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rec.status)
+
+		m.requests.WithLabelValues(route, status).Inc()
+		m.duration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,81 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prchop/chirpysrv/internal/metrics"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	m := metrics.New(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	h := m.Middleware(mux)
+
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/api/chirps", "/nope"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	tests := []string{
+		`chirpy_http_requests_total{route="GET /api/chirps/{id}",status="404"} 2`,
+		`chirpy_http_requests_total{route="GET /api/chirps",status="200"} 1`,
+		`chirpy_http_requests_total{route="unmatched",status="404"} 1`,
+		`chirpy_http_request_duration_seconds_count{route="GET /api/chirps/{id}",status="404"} 2`,
+		`chirpy_http_requests_in_flight 0`,
+	}
+	for _, want := range tests {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestBusinessCounters(t *testing.T) {
+	m := metrics.New(nil)
+
+	m.ChirpsCreated.Inc()
+	m.Logins.Inc()
+	m.FailedLogins.Add(2)
+	m.WebhooksProcessed.WithLabelValues(metrics.WebhookUpgraded).Inc()
+
+	body := scrape(t, m)
+	tests := []string{
+		`chirpy_chirps_created_total 1`,
+		`chirpy_logins_total 1`,
+		`chirpy_login_failures_total 2`,
+		`chirpy_webhooks_processed_total{result="upgraded"} 1`,
+		`chirpy_webhooks_processed_total{result="ignored"} 0`,
+	}
+	for _, want := range tests {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package pubsub fans events out to the subscribers connected to this
+// process, such as the clients of a streaming endpoint.
+package pubsub
+
+import "sync"
+
+// Hub delivers events published for a key to every subscriber of that
+// key. The zero value is not usable, create hubs with New.
+type Hub[K comparable, T any] struct {
+	mu     sync.Mutex
+	subs   map[K]map[chan T]struct{}
+	buffer int
+}
+
+// New returns a hub whose subscribers can fall behind by buffer events
+// before they start missing them.
+func New[K comparable, T any](buffer int) *Hub[K, T] {
+	return &Hub[K, T]{
+		subs:   make(map[K]map[chan T]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscribe returns a channel receiving the events published for key.
+// Calling cancel unsubscribes and closes the channel.
+func (h *Hub[K, T]) Subscribe(key K) (events <-chan T, cancel func()) {
+	ch := make(chan T, h.buffer)
+
+	h.mu.Lock()
+	if h.subs[key] == nil {
+		h.subs[key] = make(map[chan T]struct{})
+	}
+	h.subs[key][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subs[key], ch)
+			if len(h.subs[key]) == 0 {
+				delete(h.subs, key)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers event to the subscribers of key. It never blocks, a
+// subscriber whose buffer is full misses the event.
+func (h *Hub[K, T]) Publish(key K, event T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[key] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/prchop/chirpysrv/internal/pubsub"
+)
+
+func TestHubPublish(t *testing.T) {
+	hub := pubsub.New[string, int](1)
+
+	alice, cancelAlice := hub.Subscribe("alice")
+	defer cancelAlice()
+	bob, cancelBob := hub.Subscribe("bob")
+	defer cancelBob()
+
+	hub.Publish("alice", 1)
+
+	if got := <-alice; got != 1 {
+		t.Errorf("got: %d, want: 1", got)
+	}
+
+	select {
+	case got := <-bob:
+		t.Errorf("bob got %d, want nothing", got)
+	default:
+	}
+}
+
+func TestHubDropsEventsForSlowSubscribers(t *testing.T) {
+	hub := pubsub.New[string, int](1)
+
+	events, cancel := hub.Subscribe("alice")
+	defer cancel()
+
+	hub.Publish("alice", 1)
+	hub.Publish("alice", 2)
+
+	if got := <-events; got != 1 {
+		t.Errorf("got: %d, want: 1", got)
+	}
+
+	select {
+	case got := <-events:
+		t.Errorf("got %d, want the second event to be dropped", got)
+	default:
+	}
+}
+
+func TestHubCancel(t *testing.T) {
+	hub := pubsub.New[string, int](1)
+
+	events, cancel := hub.Subscribe("alice")
+	cancel()
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Error("want the channel to be closed")
+	}
+
+	// publishing without subscribers must not panic
+	hub.Publish("alice", 1)
+}
@@ -0,0 +1,197 @@
+// Package replay plays recorded HTTP requests against a running server
+// and reports which responses didn't match and how long they took, for
+// regression and load testing.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Request is one line of a script. Method, path, header values and body
+// can refer to variables as {{name}}.
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	// Body is sent as JSON.
+	Body json.RawMessage `json:"body"`
+	// ExpectStatus is the status the response must have. Zero accepts
+	// any 2xx status.
+	ExpectStatus int `json:"expect_status"`
+	// Capture maps variable names to a dotted path into the JSON
+	// response, e.g. "token" or "0.id", for later requests to use.
+	Capture map[string]string `json:"capture"`
+
+	// Line is where the request is in the script.
+	Line int `json:"-"`
+}
+
+// ErrMalformed is returned for script lines that aren't a valid request.
+var ErrMalformed = errors.New("malformed request")
+
+// maxLineSize bounds a single request of a script.
+const maxLineSize = 1 << 20
+
+// Load reads a script, one request per line. Blank lines are skipped.
+func Load(r io.Reader) ([]Request, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	var reqs []Request
+	line := 0
+	for s.Scan() {
+		line++
+		text := bytes.TrimSpace(s.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var req Request
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return nil, fmt.Errorf("line %d: %w: %v", line, ErrMalformed, err)
+		}
+		if req.Method == "" || !strings.HasPrefix(req.Path, "/") {
+			return nil, fmt.Errorf("line %d: %w: needs a method and a path starting with /", line, ErrMalformed)
+		}
+		req.Line = line
+		reqs = append(reqs, req)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return reqs, nil
+}
+
+var varPattern = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// expand replaces the variables in s. escape is applied to each value
+// before it is inserted.
+func expand(s string, vars map[string]string, escape func(string) string) (string, error) {
+	var missing string
+	out := varPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-2]
+		v, ok := vars[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return m
+		}
+		if escape != nil {
+			return escape(v)
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable %q", missing)
+	}
+	return out, nil
+}
+
+// jsonEscape escapes v to be inserted between the quotes of a JSON
+// string.
+func jsonEscape(v string) string {
+	b, _ := json.Marshal(v)
+	return string(b[1 : len(b)-1])
+}
+
+// build returns the HTTP request for req with the variables replaced.
+func (req Request) build(baseURL string, vars map[string]string) (*http.Request, error) {
+	method, err := expand(req.Method, vars, nil)
+	if err != nil {
+		return nil, err
+	}
+	path, err := expand(req.Path, vars, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if len(req.Body) > 0 {
+		b, err := expand(string(req.Body), vars, jsonEscape)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(b)
+	}
+
+	r, err := http.NewRequest(method, strings.TrimSuffix(baseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.Headers {
+		if v, err = expand(v, vars, nil); err != nil {
+			return nil, err
+		}
+		r.Header.Set(k, v)
+	}
+	return r, nil
+}
+
+// lookup follows a dotted path into a decoded JSON value. Numeric
+// segments index arrays.
+func lookup(v any, path string) (string, error) {
+	for _, seg := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[seg]
+			if !ok {
+				return "", fmt.Errorf("no %q in response", path)
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("no %q in response", path)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("no %q in response", path)
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case nil, map[string]any, []any:
+		return "", fmt.Errorf("%q is not a scalar", path)
+	default:
+		// numbers and booleans keep their JSON form
+		b, _ := json.Marshal(v)
+		return string(b), nil
+	}
+}
+
+// capture sets the variables req captures from the response body.
+func (req Request) capture(body []byte, vars map[string]string) error {
+	if len(req.Capture) == 0 {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("response is not JSON: %w", err)
+	}
+	for name, path := range req.Capture {
+		value, err := lookup(v, path)
+		if err != nil {
+			return err
+		}
+		vars[name] = value
+	}
+	return nil
+}
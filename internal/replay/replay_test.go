@@ -0,0 +1,226 @@
+package replay_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prchop/chirpysrv/internal/replay"
+)
+
+// newServer serves a login that hands out a token and an endpoint that
+// needs it.
+func newServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var logins atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		logins.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"token": "token-" + params.Email,
+			"user":  map[string]any{"id": 42},
+		})
+	})
+	mux.HandleFunc("GET /api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") || r.PathValue("id") != "42" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &logins
+}
+
+const script = `
+{"method":"POST","path":"/api/login","body":{"email":"user{{worker}}-{{iteration}}@example.com"},"capture":{"token":"token","user_id":"user.id"}}
+{"method":"GET","path":"/api/users/{{user_id}}","headers":{"Authorization":"Bearer {{token}}"},"expect_status":200}
+{"method":"GET","path":"/api/users/{{user_id}}","expect_status":401}
+`
+
+func TestRun(t *testing.T) {
+	srv, logins := newServer(t)
+
+	reqs, err := replay.Load(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs[0].Line != 2 {
+		t.Errorf("got line %d, want 2", reqs[0].Line)
+	}
+
+	report := replay.Run(context.Background(), reqs, replay.Options{
+		BaseURL:     srv.URL,
+		Concurrency: 3,
+		Iterations:  2,
+	})
+
+	if !report.Passed() {
+		t.Fatalf("got mismatches: %v", report.Mismatches)
+	}
+	if report.Requests != 18 {
+		t.Errorf("got %d requests, want 18", report.Requests)
+	}
+	if logins.Load() != 6 {
+		t.Errorf("got %d logins, want 6", logins.Load())
+	}
+	if len(report.Latencies) != 18 {
+		t.Errorf("got %d latencies, want 18", len(report.Latencies))
+	}
+}
+
+func TestRunMismatches(t *testing.T) {
+	srv, _ := newServer(t)
+
+	reqs, err := replay.Load(strings.NewReader(`
+{"method":"GET","path":"/api/users/42","expect_status":200}
+{"method":"GET","path":"/api/users/{{missing}}"}
+{"method":"POST","path":"/api/login","body":{"email":"a"},"capture":{"x":"nope"}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := replay.Run(context.Background(), reqs, replay.Options{BaseURL: srv.URL})
+	if report.Passed() {
+		t.Fatal("run passed, want it to fail")
+	}
+	if report.Failed != 3 || len(report.Mismatches) != 3 {
+		t.Fatalf("got %d failed, mismatches: %v", report.Failed, report.Mismatches)
+	}
+
+	tests := []struct {
+		want int
+		got  int
+		err  string
+	}{
+		{200, 401, ""},
+		{0, 0, `undefined variable "missing"`},
+		{0, 200, `no "nope" in response`},
+	}
+	for i, tc := range tests {
+		m := report.Mismatches[i]
+		if m.Want != tc.want || m.Got != tc.got || m.Err != tc.err {
+			t.Errorf("mismatch %d: got: %+v, want status %d/%d and err %q", i, m, tc.want, tc.got, tc.err)
+		}
+	}
+
+	var out strings.Builder
+	if err = report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "FAIL: 3 requests, 3 failed") {
+		t.Errorf("got report:\n%s", out.String())
+	}
+}
+
+func TestRunRate(t *testing.T) {
+	srv, _ := newServer(t)
+
+	reqs, err := replay.Load(strings.NewReader(`{"method":"GET","path":"/api/users/1","expect_status":401}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := replay.Run(context.Background(), reqs, replay.Options{
+		BaseURL:     srv.URL,
+		Concurrency: 2,
+		Iterations:  3,
+		Rate:        100,
+	})
+	if !report.Passed() {
+		t.Fatalf("got mismatches: %v", report.Mismatches)
+	}
+	// six requests at 100 per second take at least 60ms
+	if report.Duration < 50*time.Millisecond {
+		t.Errorf("got duration %s, want the rate to slow it down", report.Duration)
+	}
+
+	// too high to space out, so it runs unlimited instead of panicking
+	report = replay.Run(context.Background(), reqs, replay.Options{
+		BaseURL: srv.URL,
+		Rate:    2 * replay.MaxRate,
+	})
+	if !report.Passed() {
+		t.Fatalf("got mismatches: %v", report.Mismatches)
+	}
+
+	// too low to space out, so it waits for the first tick until the
+	// context ends instead of panicking
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report = replay.Run(ctx, reqs, replay.Options{
+		BaseURL: srv.URL,
+		Rate:    replay.MinRate / 2,
+	})
+	if report.Requests != 0 {
+		t.Errorf("got %d requests, want none", report.Requests)
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not json", `{"method":`},
+		{"unknown field", `{"method":"GET","path":"/","status":200}`},
+		{"no method", `{"path":"/"}`},
+		{"relative path", `{"method":"GET","path":"api"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := replay.Load(strings.NewReader(`{"method":"GET","path":"/"}` + "\n" + tc.input))
+			if !errors.Is(err, replay.ErrMalformed) {
+				t.Fatalf("got: %v, want ErrMalformed", err)
+			}
+			if !strings.HasPrefix(err.Error(), "line 2:") {
+				t.Errorf("got: %q, want it to name line 2", err)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{90, 9 * time.Millisecond},
+		{99, 10 * time.Millisecond},
+		{100, 10 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		if got := replay.Percentile(sorted, tc.p); got != tc.want {
+			t.Errorf("p%v: got: %s, want: %s", tc.p, got, tc.want)
+		}
+	}
+
+	if got := replay.Percentile(nil, 50); got != 0 {
+		t.Errorf("empty: got: %s, want 0", got)
+	}
+}
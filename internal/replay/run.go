@@ -0,0 +1,291 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// The rates a ticker can space out. MaxRate is one request per
+// nanosecond, MinRate one every 2^62 nanoseconds, or about 146 years,
+// well before the interval overflows.
+const (
+	MinRate = float64(time.Second) / (1 << 62)
+	MaxRate = float64(time.Second)
+)
+
+type Options struct {
+	// BaseURL is prepended to the path of every request.
+	BaseURL string
+	// Concurrency is how many workers run the script side by side, each
+	// with variables of its own.
+	Concurrency int
+	// Iterations is how often each worker runs the script.
+	Iterations int
+	// Rate caps the requests per second of all workers together. Zero,
+	// or anything above MaxRate, doesn't limit them, and rates below
+	// MinRate are raised to it.
+	Rate float64
+	// Vars are set for every worker, along with "worker" and
+	// "iteration", which help to keep emails and such unique.
+	Vars   map[string]string
+	Client *http.Client
+}
+
+// Mismatch is a request whose response wasn't the expected one.
+type Mismatch struct {
+	Line      int
+	Worker    int
+	Iteration int
+	Method    string
+	Path      string
+	// Want and Got are the statuses. Got is zero when there was no
+	// response.
+	Want int
+	Got  int
+	Err  string
+}
+
+func (m Mismatch) String() string {
+	want := "2xx"
+	if m.Want != 0 {
+		want = strconv.Itoa(m.Want)
+	}
+	s := fmt.Sprintf("line %d (worker %d, iteration %d): %s %s: want %s, got %d",
+		m.Line, m.Worker, m.Iteration, m.Method, m.Path, want, m.Got)
+	if m.Err != "" {
+		s += ": " + m.Err
+	}
+	return s
+}
+
+// Step holds the results of one request of the script.
+type Step struct {
+	Line      int
+	Method    string
+	Path      string
+	Count     int
+	Failed    int
+	Latencies []time.Duration
+}
+
+type Report struct {
+	Requests   int
+	Failed     int
+	Duration   time.Duration
+	Steps      []*Step
+	Mismatches []Mismatch
+	// Latencies of all requests, sorted.
+	Latencies []time.Duration
+}
+
+// Passed reports whether every response matched.
+func (r *Report) Passed() bool {
+	return r.Failed == 0
+}
+
+// Percentile returns the latency at or below which p percent of the
+// requests finished, by the nearest-rank method.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// Run plays reqs until every worker has run its iterations or ctx is
+// cancelled.
+func Run(ctx context.Context, reqs []Request, opts Options) *Report {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Iterations < 1 {
+		opts.Iterations = 1
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	// a shared ticker spaces out the requests of all workers
+	var tick <-chan time.Time
+	if opts.Rate > 0 && opts.Rate <= MaxRate {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / max(opts.Rate, MinRate)))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	report := &Report{Steps: make([]*Step, len(reqs))}
+	for i, req := range reqs {
+		report.Steps[i] = &Step{Line: req.Line, Method: req.Method, Path: req.Path}
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	record := func(i int, latency time.Duration, m *Mismatch) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		report.Requests++
+		step := report.Steps[i]
+		step.Count++
+		if latency > 0 {
+			step.Latencies = append(step.Latencies, latency)
+			report.Latencies = append(report.Latencies, latency)
+		}
+		if m != nil {
+			report.Failed++
+			step.Failed++
+			report.Mismatches = append(report.Mismatches, *m)
+		}
+	}
+
+	start := time.Now()
+	for worker := range opts.Concurrency {
+		wg.Go(func() {
+			for iteration := range opts.Iterations {
+				vars := maps.Clone(opts.Vars)
+				if vars == nil {
+					vars = make(map[string]string)
+				}
+				vars["worker"] = strconv.Itoa(worker)
+				vars["iteration"] = strconv.Itoa(iteration)
+
+				for i, req := range reqs {
+					if tick != nil {
+						select {
+						case <-tick:
+						case <-ctx.Done():
+						}
+					}
+					if ctx.Err() != nil {
+						return
+					}
+
+					latency, m := play(ctx, opts, req, vars)
+					if m != nil {
+						m.Worker, m.Iteration = worker, iteration
+					}
+					record(i, latency, m)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	slices.Sort(report.Latencies)
+	for _, step := range report.Steps {
+		slices.Sort(step.Latencies)
+	}
+	slices.SortFunc(report.Mismatches, func(a, b Mismatch) int {
+		if a.Worker != b.Worker {
+			return a.Worker - b.Worker
+		}
+		if a.Iteration != b.Iteration {
+			return a.Iteration - b.Iteration
+		}
+		return a.Line - b.Line
+	})
+	return report
+}
+
+// play sends one request. The latency is zero when no response arrived.
+func play(ctx context.Context, opts Options, req Request, vars map[string]string) (time.Duration, *Mismatch) {
+	m := &Mismatch{Line: req.Line, Method: req.Method, Path: req.Path, Want: req.ExpectStatus}
+
+	r, err := req.build(opts.BaseURL, vars)
+	if err != nil {
+		m.Err = err.Error()
+		return 0, m
+	}
+	m.Path = r.URL.Path
+
+	start := time.Now()
+	resp, err := opts.Client.Do(r.WithContext(ctx))
+	if err != nil {
+		m.Err = err.Error()
+		return 0, m
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+	m.Got = resp.StatusCode
+	if err != nil {
+		m.Err = err.Error()
+		return latency, m
+	}
+
+	if !statusMatches(req.ExpectStatus, resp.StatusCode) {
+		return latency, m
+	}
+	if err = req.capture(body, vars); err != nil {
+		m.Err = err.Error()
+		return latency, m
+	}
+	return latency, nil
+}
+
+func statusMatches(want, got int) bool {
+	if want == 0 {
+		return got >= 200 && got < 300
+	}
+	return want == got
+}
+
+// maxMismatches is how many mismatches Write lists.
+const maxMismatches = 20
+
+// Write prints the report: the mismatches, the latencies of each step
+// and overall, and whether the run passed.
+func (r *Report) Write(w io.Writer) error {
+	for i, m := range r.Mismatches {
+		if i == maxMismatches {
+			fmt.Fprintf(w, "... and %d more mismatches\n", len(r.Mismatches)-i)
+			break
+		}
+		fmt.Fprintln(w, m)
+	}
+	if len(r.Mismatches) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tREQUEST\tCOUNT\tFAILED\tP50\tP90\tP99\tMAX\t")
+	for _, s := range r.Steps {
+		fmt.Fprintf(tw, "%d\t%s %s\t%d\t%d\t%s\n", s.Line, s.Method, s.Path,
+			s.Count, s.Failed, percentiles(s.Latencies))
+	}
+	fmt.Fprintf(tw, "\tall\t%d\t%d\t%s\n", r.Requests, r.Failed, percentiles(r.Latencies))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	result := "PASS"
+	if !r.Passed() {
+		result = "FAIL"
+	}
+	rps := float64(r.Requests) / r.Duration.Seconds()
+	_, err := fmt.Fprintf(w, "\n%s: %d requests, %d failed in %s (%.1f req/s)\n",
+		result, r.Requests, r.Failed, r.Duration.Round(time.Millisecond), rps)
+	return err
+}
+
+func percentiles(sorted []time.Duration) string {
+	var s string
+	for _, p := range []float64{50, 90, 99, 100} {
+		s += Percentile(sorted, p).Round(10*time.Microsecond).String() + "\t"
+	}
+	return s
+}
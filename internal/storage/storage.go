@@ -0,0 +1,22 @@
+// Package storage keeps uploaded files behind the BlobStore interface so
+// the server can run against a local directory or an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type BlobStore interface {
+	// Put stores data under key, replacing any existing blob.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get opens the blob stored under key. It returns ErrNotFound when
+	// there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+}
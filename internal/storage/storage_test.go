@@ -0,0 +1,127 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prchop/chirpysrv/internal/storage"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible service.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newStores(t *testing.T) map[string]storage.BlobStore {
+	t.Helper()
+
+	fsStore, err := storage.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	t.Cleanup(srv.Close)
+
+	s3Store, err := storage.NewS3Store(storage.S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "chirpy",
+		AccessKey: "access",
+		SecretKey: "secret",
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]storage.BlobStore{"fs": fsStore, "s3": s3Store}
+}
+
+func TestBlobStoreRoundTrip(t *testing.T) {
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			want := "image bytes"
+
+			if err := store.Put(ctx, "a.jpg", []byte(want), "image/jpeg"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+
+			rc, err := store.Get(ctx, "a.jpg")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(got) != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+
+			if err = store.Delete(ctx, "a.jpg"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+
+			if _, err = store.Get(ctx, "a.jpg"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("got: %v, want: %v", err, storage.ErrNotFound)
+			}
+
+			if err = store.Delete(ctx, "a.jpg"); err != nil {
+				t.Errorf("deleting a missing blob: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStoreRejectsPathKeys(t *testing.T) {
+	store, err := storage.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "../escape", "dir/file", ".hidden"} {
+		if err := store.Put(context.Background(), key, []byte("x"), ""); err == nil {
+			t.Errorf("key %q: want error but got none", key)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// DBTX is the interface sqlc generated queries run through.
+type DBTX interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// DB wraps a DBTX so every query gets a span, as a child of the span in
+// the context it was run with.
+type DB struct {
+	db DBTX
+}
+
+// WrapDB returns db with tracing.
+func WrapDB(db DBTX) *DB {
+	return &DB{db: db}
+}
+
+// queryName returns the name sqlc puts in the first line of a query,
+// "-- name: GetChirps :many", or "query" without one.
+func queryName(query string) string {
+	line, _, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	return "query"
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := querySpan(ctx, query)
+	res, err := d.db.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := querySpan(ctx, query)
+	stmt, err := d.db.PrepareContext(ctx, query)
+	endSpan(span, err)
+	return stmt, err
+}
+
+// QueryContext ends the span once the query returned, reading the rows
+// is not part of it.
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := querySpan(ctx, query)
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := querySpan(ctx, query)
+	row := d.db.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
+}
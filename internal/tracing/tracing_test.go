@@ -0,0 +1,178 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prchop/chirpysrv/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a provider that keeps every ended span.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	return rec
+}
+
+// fakeDB runs nothing and fails with err.
+type fakeDB struct {
+	err error
+}
+
+func (f fakeDB) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryRowContext(context.Context, string, ...any) *sql.Row {
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := record(t)
+	db := tracing.WrapDB(fakeDB{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		db.QueryContext(r.Context(), "-- name: GetChirpByID :one\nSELECT 1")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	tracing.Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	if req.Pattern != "GET /api/chirps/{id}" {
+		t.Errorf("got pattern %q on the outer request, want the route pattern", req.Pattern)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	query, server := spans[0], spans[1]
+
+	if server.Name() != "GET /api/chirps/{id}" {
+		t.Errorf("got server span %q, want the route pattern", server.Name())
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("got trace %s, want %s", got, traceID)
+	}
+	if !server.Parent().IsRemote() {
+		t.Error("server span doesn't continue the incoming trace")
+	}
+	if server.Status().Code != codes.Error {
+		t.Errorf("got status %v, want error for a 500", server.Status().Code)
+	}
+
+	if query.Name() != "GetChirpByID" {
+		t.Errorf("got query span %q, want GetChirpByID", query.Name())
+	}
+	if query.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("query span is not a child of the server span")
+	}
+}
+
+func TestDBSpans(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		err      error
+		wantName string
+		wantCode codes.Code
+	}{
+		{
+			name:     "sqlc query",
+			query:    "-- name: CreateChirp :one\nINSERT INTO chirps",
+			wantName: "CreateChirp",
+			wantCode: codes.Unset,
+		},
+		{
+			name:     "failed query",
+			query:    "-- name: DeleteChirp :exec\nDELETE FROM chirps",
+			err:      errors.New("connection reset"),
+			wantName: "DeleteChirp",
+			wantCode: codes.Error,
+		},
+		{
+			name:     "plain query",
+			query:    "SELECT 1",
+			wantName: "query",
+			wantCode: codes.Unset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := record(t)
+
+			_, err := tracing.WrapDB(fakeDB{err: tt.err}).ExecContext(context.Background(), tt.query)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got err: %v, want: %v", err, tt.err)
+			}
+
+			spans := rec.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			if spans[0].Name() != tt.wantName {
+				t.Errorf("got: %q, want: %q", spans[0].Name(), tt.wantName)
+			}
+			if spans[0].Status().Code != tt.wantCode {
+				t.Errorf("got status %v, want %v", spans[0].Status().Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		exporter string
+		wantErr  bool
+	}{
+		{exporter: tracing.ExporterNone},
+		{exporter: tracing.ExporterStdout},
+		{exporter: "jaeger", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exporter, func(t *testing.T) {
+			prev := otel.GetTracerProvider()
+			t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+			shutdown, err := tracing.Setup(context.Background(), tt.exporter, "chirpy-test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err: %v, wantErr: %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err = shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown: %v", err)
+				}
+			}
+		})
+	}
+}
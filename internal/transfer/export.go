@@ -0,0 +1,161 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// pageSize is how many rows an export reads per query.
+const pageSize = 1000
+
+type ExportOptions struct {
+	// Passwords includes the password hashes of users.
+	Passwords bool
+}
+
+// Counts holds a number per record kind.
+type Counts struct {
+	Users   int `json:"users"`
+	Chirps  int `json:"chirps"`
+	Follows int `json:"follows"`
+}
+
+func (c *Counts) add(v any) {
+	switch v.(type) {
+	case User:
+		c.Users++
+	case Chirp:
+		c.Chirps++
+	case Follow:
+		c.Follows++
+	}
+}
+
+func (c *Counts) merge(o Counts) {
+	c.Users += o.Users
+	c.Chirps += o.Chirps
+	c.Follows += o.Follows
+}
+
+// Export writes the users, chirps and follows to w a page at a time.
+// Deleted users and chirps, and follows of deleted users, are left out.
+// Everything is read in one repeatable-read transaction, so the export is
+// a consistent snapshot even while the server keeps writing.
+func (t *Transfer) Export(ctx context.Context, w io.Writer, opts ExportOptions) (Counts, error) {
+	var counts Counts
+
+	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return counts, err
+	}
+	defer tx.Rollback()
+	qtx := t.queries(tx)
+
+	enc := NewEncoder(w)
+	encode := func(v any) error {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		counts.add(v)
+		return nil
+	}
+
+	if err = exportUsers(ctx, qtx, opts, encode); err != nil {
+		return counts, err
+	}
+	if err = exportChirps(ctx, qtx, encode); err != nil {
+		return counts, err
+	}
+	if err = exportFollows(ctx, qtx, encode); err != nil {
+		return counts, err
+	}
+
+	return counts, enc.Flush()
+}
+
+func exportUsers(ctx context.Context, q *database.Queries, opts ExportOptions, encode func(any) error) error {
+	var afterTime time.Time
+	var afterID uuid.UUID
+	for {
+		users, err := q.ExportUsers(ctx, database.ExportUsersParams{
+			AfterTime: afterTime,
+			AfterID:   afterID,
+			PageSize:  pageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, u := range users {
+			if err = encode(newUser(u, opts.Passwords)); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < pageSize {
+			return nil
+		}
+		last := users[len(users)-1]
+		afterTime, afterID = last.CreatedAt, last.ID
+	}
+}
+
+func exportChirps(ctx context.Context, q *database.Queries, encode func(any) error) error {
+	var afterTime time.Time
+	var afterID uuid.UUID
+	for {
+		chirps, err := q.ExportChirps(ctx, database.ExportChirpsParams{
+			AfterTime: afterTime,
+			AfterID:   afterID,
+			PageSize:  pageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range chirps {
+			if err = encode(newChirp(c)); err != nil {
+				return err
+			}
+		}
+
+		if len(chirps) < pageSize {
+			return nil
+		}
+		last := chirps[len(chirps)-1]
+		afterTime, afterID = last.CreatedAt, last.ID
+	}
+}
+
+func exportFollows(ctx context.Context, q *database.Queries, encode func(any) error) error {
+	var afterTime time.Time
+	var afterFollower, afterFollowee uuid.UUID
+	for {
+		follows, err := q.ExportFollows(ctx, database.ExportFollowsParams{
+			AfterTime:       afterTime,
+			AfterFollowerID: afterFollower,
+			AfterFolloweeID: afterFollowee,
+			PageSize:        pageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range follows {
+			if err = encode(newFollow(f)); err != nil {
+				return err
+			}
+		}
+
+		if len(follows) < pageSize {
+			return nil
+		}
+		last := follows[len(follows)-1]
+		afterTime, afterFollower, afterFollowee = last.CreatedAt, last.FollowerID, last.FolloweeID
+	}
+}
@@ -0,0 +1,268 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// Policy decides what an import does with a record that already exists.
+type Policy string
+
+const (
+	// PolicySkip keeps the existing row.
+	PolicySkip Policy = "skip"
+	// PolicyOverwrite replaces the existing row with the record.
+	PolicyOverwrite Policy = "overwrite"
+	// PolicyFail stops the import with ErrConflict.
+	PolicyFail Policy = "fail"
+)
+
+// ErrConflict is returned under PolicyFail for a record that already
+// exists, and under every policy for a user whose email belongs to
+// another user.
+var ErrConflict = errors.New("record already exists")
+
+// emailConstraint is the unique constraint on the email of users.
+const emailConstraint = "users_email_key"
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicySkip, PolicyOverwrite, PolicyFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q", s)
+	}
+}
+
+// DefaultBatchSize is how many records an import writes per transaction
+// unless told otherwise.
+const DefaultBatchSize = 500
+
+type ImportOptions struct {
+	Policy Policy
+	// DryRun runs the whole import in one transaction that is rolled
+	// back, so constraint violations are still found.
+	DryRun bool
+	// BatchSize is how many records are committed together.
+	BatchSize int
+}
+
+// ImportResult counts the records of the batches that were committed, or
+// would have been on a dry run.
+type ImportResult struct {
+	Created Counts `json:"created"`
+	Updated Counts `json:"updated"`
+	Skipped Counts `json:"skipped"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+func (r *ImportResult) merge(o ImportResult) {
+	r.Created.merge(o.Created)
+	r.Updated.merge(o.Updated)
+	r.Skipped.merge(o.Skipped)
+}
+
+type pending struct {
+	line int
+	v    any
+}
+
+// Import reads records from r and writes them in batches, each in its own
+// transaction. When a batch fails the batches before it stay committed,
+// so rerunning with PolicySkip picks up where the import stopped.
+func (t *Transfer) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{DryRun: opts.DryRun}
+	if opts.Policy == "" {
+		opts.Policy = PolicySkip
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	// a dry run can't commit between batches, so later records would
+	// miss the users they refer to
+	var dryTx *sql.Tx
+	if opts.DryRun {
+		tx, err := t.db.BeginTx(ctx, nil)
+		if err != nil {
+			return result, err
+		}
+		defer tx.Rollback()
+		dryTx = tx
+	}
+
+	dec := NewDecoder(r)
+	batch := make([]pending, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := t.importBatch(ctx, dryTx, batch, opts.Policy)
+		if err != nil {
+			return err
+		}
+		result.merge(res)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		v, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		batch = append(batch, pending{line: dec.Line(), v: v})
+		if len(batch) == opts.BatchSize {
+			if err = flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, flush()
+}
+
+// importBatch writes batch in a transaction of its own, or in tx when it
+// isn't nil.
+func (t *Transfer) importBatch(ctx context.Context, tx *sql.Tx, batch []pending, policy Policy) (ImportResult, error) {
+	var result ImportResult
+
+	commit := tx == nil
+	if commit {
+		var err error
+		tx, err = t.db.BeginTx(ctx, nil)
+		if err != nil {
+			return result, err
+		}
+		defer tx.Rollback()
+	}
+	qtx := t.queries(tx)
+
+	for _, p := range batch {
+		created, updated, err := importRecord(ctx, qtx, p.v, policy)
+		if err != nil {
+			return result, fmt.Errorf("line %d: %w", p.line, err)
+		}
+		switch {
+		case created:
+			result.Created.add(p.v)
+		case updated:
+			result.Updated.add(p.v)
+		default:
+			result.Skipped.add(p.v)
+		}
+	}
+
+	if commit {
+		if err := tx.Commit(); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// importRecord writes a single record. When it reports neither created
+// nor updated the record was skipped.
+func importRecord(ctx context.Context, q *database.Queries, v any, policy Policy) (created, updated bool, err error) {
+	switch v := v.(type) {
+	case User:
+		created, updated, err = importUser(ctx, q, v, policy)
+		if emailTaken(err) {
+			err = fmt.Errorf("user %s: email %s belongs to another user: %w", v.ID, v.Email, ErrConflict)
+		}
+		return created, updated, err
+	case Chirp:
+		if policy == PolicyOverwrite {
+			created, err = q.ImportChirpOverwrite(ctx, database.ImportChirpOverwriteParams(chirpParams(v)))
+			return created, !created, err
+		}
+		n, err := q.ImportChirp(ctx, chirpParams(v))
+		return checkInserted(n, err, policy, "chirp "+v.ID.String())
+	case Follow:
+		// a follow has nothing to overwrite
+		n, err := q.ImportFollow(ctx, database.ImportFollowParams{
+			FollowerID: v.FollowerID,
+			FolloweeID: v.FolloweeID,
+			CreatedAt:  v.CreatedAt,
+		})
+		return checkInserted(n, err, policy, "follow "+v.FollowerID.String()+" -> "+v.FolloweeID.String())
+	default:
+		return false, false, fmt.Errorf("cannot import %T", v)
+	}
+}
+
+func importUser(ctx context.Context, q *database.Queries, u User, policy Policy) (created, updated bool, err error) {
+	if policy == PolicyOverwrite {
+		created, err = q.ImportUserOverwrite(ctx, database.ImportUserOverwriteParams(userParams(u)))
+		return created, !created && err == nil, err
+	}
+	n, err := q.ImportUser(ctx, userParams(u))
+	return checkInserted(n, err, policy, "user "+u.ID.String())
+}
+
+// emailTaken reports whether err is a unique violation on the email of a
+// user, which no policy resolves as it's another user's row.
+func emailTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == emailConstraint
+}
+
+func checkInserted(n int64, err error, policy Policy, record string) (created, updated bool, _ error) {
+	if err != nil {
+		return false, false, err
+	}
+	if n == 0 && policy == PolicyFail {
+		return false, false, fmt.Errorf("%s: %w", record, ErrConflict)
+	}
+	return n > 0, false, nil
+}
+
+func userParams(u User) database.ImportUserParams {
+	return database.ImportUserParams{
+		ID:             u.ID,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+		Email:          u.Email,
+		HashedPassword: u.HashedPassword,
+		IsChirpyRed:    u.IsChirpyRed,
+		IsAdmin:        u.IsAdmin,
+		Bio:            u.Bio,
+		Location:       u.Location,
+		Website:        u.Website,
+		DmPolicy:       u.DMPolicy,
+		Protected:      u.Protected,
+	}
+}
+
+func chirpParams(c Chirp) database.ImportChirpParams {
+	params := database.ImportChirpParams{
+		ID:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Body:       c.Body,
+		UserID:     c.UserID,
+		Published:  c.Published,
+		Visibility: c.Visibility,
+	}
+	if c.EditedAt != nil {
+		params.EditedAt = sql.NullTime{Time: *c.EditedAt, Valid: true}
+	}
+	if c.PublishAt != nil {
+		params.PublishAt = sql.NullTime{Time: *c.PublishAt, Valid: true}
+	}
+	if c.QuoteOf != nil {
+		params.QuoteOf = uuid.NullUUID{UUID: *c.QuoteOf, Valid: true}
+	}
+	return params
+}
@@ -0,0 +1,267 @@
+// Package transfer exports users, chirps and follows as JSON Lines and
+// imports them again with their IDs, to move data between deployments or
+// to back it up.
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// Record kinds. An export writes all users, then chirps, then follows, so
+// every record comes after the ones it refers to.
+const (
+	KindUser   = "user"
+	KindChirp  = "chirp"
+	KindFollow = "follow"
+)
+
+// ErrMalformed is returned for lines that aren't a valid record.
+var ErrMalformed = errors.New("malformed record")
+
+// Transfer exports from and imports into a database.
+type Transfer struct {
+	db      *sql.DB
+	queries func(*sql.Tx) *database.Queries
+}
+
+// New returns a Transfer for db. queries returns the queries run in a
+// transaction, so callers can wrap them.
+func New(db *sql.DB, queries func(*sql.Tx) *database.Queries) *Transfer {
+	return &Transfer{db: db, queries: queries}
+}
+
+// Record is one line of an export.
+type Record struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+	// HashedPassword is only exported on request. Users imported without
+	// one can't log in until their password is reset.
+	HashedPassword string `json:"hashed_password,omitempty"`
+	IsChirpyRed    bool   `json:"is_chirpy_red"`
+	IsAdmin        bool   `json:"is_admin"`
+	Bio            string `json:"bio"`
+	Location       string `json:"location"`
+	Website        string `json:"website"`
+	DMPolicy       string `json:"dm_policy"`
+	Protected      bool   `json:"protected"`
+}
+
+type Chirp struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	UserID     uuid.UUID  `json:"user_id"`
+	EditedAt   *time.Time `json:"edited_at"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Published  bool       `json:"published"`
+	QuoteOf    *uuid.UUID `json:"quote_of"`
+	Visibility string     `json:"visibility"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newUser(u database.User, password bool) User {
+	user := User{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		IsChirpyRed: u.IsChirpyRed,
+		IsAdmin:     u.IsAdmin,
+		Bio:         u.Bio,
+		Location:    u.Location,
+		Website:     u.Website,
+		DMPolicy:    u.DmPolicy,
+		Protected:   u.Protected,
+	}
+	if password {
+		user.HashedPassword = u.HashedPassword
+	}
+	return user
+}
+
+func newChirp(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Body:       c.Body,
+		UserID:     c.UserID,
+		Published:  c.Published,
+		Visibility: c.Visibility,
+	}
+	if c.EditedAt.Valid {
+		chirp.EditedAt = &c.EditedAt.Time
+	}
+	if c.PublishAt.Valid {
+		chirp.PublishAt = &c.PublishAt.Time
+	}
+	if c.QuoteOf.Valid {
+		chirp.QuoteOf = &c.QuoteOf.UUID
+	}
+	return chirp
+}
+
+func newFollow(f database.Follow) Follow {
+	return Follow{
+		FollowerID: f.FollowerID,
+		FolloweeID: f.FolloweeID,
+		CreatedAt:  f.CreatedAt,
+	}
+}
+
+// Encoder writes records to a stream.
+type Encoder struct {
+	w *bufio.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes v, a User, Chirp or Follow, as one line.
+func (e *Encoder) Encode(v any) error {
+	var kind string
+	switch v.(type) {
+	case User:
+		kind = KindUser
+	case Chirp:
+		kind = KindChirp
+	case Follow:
+		kind = KindFollow
+	default:
+		return fmt.Errorf("cannot encode %T", v)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(Record{Kind: kind, Data: data})
+	if err != nil {
+		return err
+	}
+
+	if _, err = e.w.Write(line); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+// Flush writes any buffered records.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// maxLineSize bounds a single record.
+const maxLineSize = 1 << 20
+
+// Decoder reads records from a stream.
+type Decoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	return &Decoder{s: s}
+}
+
+// Line returns the line number of the last record read.
+func (d *Decoder) Line() int {
+	return d.line
+}
+
+// Next returns the next record as a User, Chirp or Follow. Blank lines
+// are skipped. It returns io.EOF after the last record.
+func (d *Decoder) Next() (any, error) {
+	for d.s.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		v, err := decodeRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", d.line, err)
+		}
+		return v, nil
+	}
+
+	if err := d.s.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", d.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+func decodeRecord(line []byte) (any, error) {
+	var rec Record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	switch rec.Kind {
+	case KindUser:
+		var u User
+		if err := decodeData(rec.Data, &u); err != nil {
+			return nil, err
+		}
+		if u.ID == uuid.Nil || u.Email == "" {
+			return nil, fmt.Errorf("%w: user needs an id and an email", ErrMalformed)
+		}
+		return u, nil
+	case KindChirp:
+		var c Chirp
+		if err := decodeData(rec.Data, &c); err != nil {
+			return nil, err
+		}
+		if c.ID == uuid.Nil || c.UserID == uuid.Nil {
+			return nil, fmt.Errorf("%w: chirp needs an id and a user_id", ErrMalformed)
+		}
+		return c, nil
+	case KindFollow:
+		var f Follow
+		if err := decodeData(rec.Data, &f); err != nil {
+			return nil, err
+		}
+		if f.FollowerID == uuid.Nil || f.FolloweeID == uuid.Nil {
+			return nil, fmt.Errorf("%w: follow needs a follower_id and a followee_id", ErrMalformed)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrMalformed, rec.Kind)
+	}
+}
+
+func decodeData(data json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return nil
+}
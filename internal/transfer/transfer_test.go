@@ -0,0 +1,136 @@
+package transfer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/transfer"
+)
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	quoted := uuid.New()
+	userID := uuid.New()
+
+	records := []any{
+		transfer.User{
+			ID:        userID,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Email:     "alice@example.com",
+			DMPolicy:  "everyone",
+			Protected: true,
+		},
+		transfer.Chirp{
+			ID:         uuid.New(),
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			Body:       "hello",
+			UserID:     userID,
+			EditedAt:   &now,
+			Published:  true,
+			QuoteOf:    &quoted,
+			Visibility: "public",
+		},
+		transfer.Follow{
+			FollowerID: userID,
+			FolloweeID: uuid.New(),
+			CreatedAt:  now,
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := transfer.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(buf.String(), "\n"); n != len(records) {
+		t.Fatalf("got %d lines, want %d", n, len(records))
+	}
+	if strings.Contains(buf.String(), "hashed_password") {
+		t.Error("empty password hash was written")
+	}
+
+	dec := transfer.NewDecoder(&buf)
+	for i, want := range records {
+		got, err := dec.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d: got: %+v, want: %+v", i, got, want)
+		}
+		if dec.Line() != i+1 {
+			t.Errorf("record %d: got line %d, want %d", i, dec.Line(), i+1)
+		}
+	}
+	if _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("got: %v, want io.EOF", err)
+	}
+}
+
+func TestDecoderMalformed(t *testing.T) {
+	id := uuid.New().String()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not json", `{"kind":`},
+		{"unknown kind", `{"kind":"bookmark","data":{}}`},
+		{"unknown field", `{"kind":"follow","data":{"follower_id":"` + id + `","followee_id":"` + id + `","extra":1}}`},
+		{"user without email", `{"kind":"user","data":{"id":"` + id + `"}}`},
+		{"chirp without user", `{"kind":"chirp","data":{"id":"` + id + `","body":"hi"}}`},
+		{"follow without followee", `{"kind":"follow","data":{"follower_id":"` + id + `"}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the blank line is skipped but still counted
+			dec := transfer.NewDecoder(strings.NewReader("\n" + tc.input + "\n"))
+			_, err := dec.Next()
+			if !errors.Is(err, transfer.ErrMalformed) {
+				t.Fatalf("got: %v, want ErrMalformed", err)
+			}
+			if !strings.HasPrefix(err.Error(), "line 2:") {
+				t.Errorf("got: %q, want it to name line 2", err)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    transfer.Policy
+		wantErr bool
+	}{
+		{"skip", transfer.PolicySkip, false},
+		{"overwrite", transfer.PolicyOverwrite, false},
+		{"fail", transfer.PolicyFail, false},
+		{"", "", true},
+		{"replace", "", true},
+	}
+
+	for _, tc := range tests {
+		got, err := transfer.ParsePolicy(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: got err %v, want error %t", tc.input, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("%q: got: %q, want: %q", tc.input, got, tc.want)
+		}
+	}
+}
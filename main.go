@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/prchop/chirpysrv/internal/logging"
+	"github.com/prchop/chirpysrv/internal/tracing"
 )
 
 func main() {
@@ -19,12 +30,44 @@ func main() {
 	if err != nil {
 		log.Fatal("Error parsing config", err)
 	}
+	if err = cfg.validate(); err != nil {
+		log.Fatal("Invalid config: ", err)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatal("Error configuring logging", err)
+	}
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), cfg, os.Args[1:])
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			slog.Error("error running command", "command", os.Args[1], "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter, "chirpy")
+	if err != nil {
+		slog.Error("error setting up tracing", "err", err)
+		os.Exit(1)
+	}
 
 	port := cfg.Port
 	mux := http.NewServeMux()
 	app, err := NewApp(cfg)
 	if err != nil {
-		log.Fatal("Error starting app", err)
+		slog.Error("error starting app", "err", err)
+		os.Exit(1)
+	}
+
+	if cfg.MigrateOnStart {
+		if err = migrateOnStart(context.Background(), app.conn); err != nil {
+			slog.Error("error applying migrations", "err", err)
+			os.Exit(1)
+		}
 	}
 
 	mw := func(h http.Handler) http.Handler {
@@ -33,13 +76,33 @@ func main() {
 
 	mux.Handle("/app/", mw(appHandler("./web")))
 
-	mux.Handle("GET /api/health", mw(http.HandlerFunc(healthHandler)))
+	mux.Handle("GET /media/{key}", mw(serveMediaHandler(app)))
+
+	mux.Handle("GET /healthz", http.HandlerFunc(livenessHandler))
+	mux.Handle("GET /readyz", readinessHandler(app))
+	mux.Handle("GET /api/health", mw(http.HandlerFunc(livenessHandler)))
 
 	mux.Handle("GET /api/users", mw(getUsersHandler(app)))
 	mux.Handle("GET /api/users/{id}", mw(getUserByIDHandler(app)))
+	mux.Handle("GET /api/users/{id}/profile", mw(getUserProfileHandler(app)))
 
 	mux.Handle("GET /api/chirps", mw(getChirpsHandler(app)))
+	mux.Handle("GET /api/chirps/scheduled", mw(getScheduledChirpsHandler(app)))
 	mux.Handle("GET /api/chirps/{id}", mw(getChripByIDHandler(app)))
+	mux.Handle("GET /api/chirps/{id}/revisions", mw(getChirpRevisionsHandler(app)))
+	mux.Handle("GET /api/chirps/{id}/quotes", mw(getChirpQuotesHandler(app)))
+	mux.Handle("GET /api/notifications", mw(getNotificationsHandler(app)))
+	mux.Handle("GET /api/bookmarks", mw(getBookmarksHandler(app)))
+	mux.Handle("GET /api/blocks", mw(getBlocksHandler(app)))
+	mux.Handle("GET /api/mutes", mw(getMutesHandler(app)))
+	mux.Handle("GET /api/muted_words", mw(getMutedWordsHandler(app)))
+	mux.Handle("GET /api/conversations", mw(getConversationsHandler(app)))
+	mux.Handle("GET /api/conversations/{id}", mw(getConversationByIDHandler(app)))
+	mux.Handle("GET /api/conversations/{id}/messages", mw(getMessagesHandler(app)))
+	mux.Handle("GET /api/stream", mw(streamHandler(app)))
+	mux.Handle("GET /api/collections", mw(getCollectionsHandler(app)))
+	mux.Handle("GET /api/follow-requests", mw(getFollowRequestsHandler(app)))
+	mux.Handle("GET /api/collections/{id}", mw(getCollectionByIDHandler(app)))
 
 	mux.Handle("POST /api/users", mw(userHandler(app)))
 	mux.Handle("POST /api/login", mw(userLoginHandler(app)))
@@ -47,18 +110,115 @@ func main() {
 	mux.Handle("POST /api/refresh", mw(refreshHandler(app)))
 	mux.Handle("POST /api/revoke", mw(revokeHandler(app)))
 	mux.Handle("POST /api/polka/webhooks", mw(upgradeUserHandler(app)))
+	mux.Handle("POST /api/chirps/{id}/restore", mw(restoreChirpHandler(app)))
+	mux.Handle("POST /api/chirps/{id}/poll/vote", mw(votePollHandler(app)))
+	mux.Handle("POST /api/chirps/{id}/bookmark", mw(bookmarkChirpHandler(app)))
+	mux.Handle("POST /api/chirps/{id}/pin", mw(pinChirpHandler(app)))
+	mux.Handle("POST /api/users/{id}/follow", mw(followUserHandler(app)))
+	mux.Handle("POST /api/follow-requests/{id}/approve", mw(approveFollowRequestHandler(app)))
+	mux.Handle("POST /api/follow-requests/{id}/reject", mw(rejectFollowRequestHandler(app)))
+	mux.Handle("POST /api/users/{id}/block", mw(blockUserHandler(app)))
+	mux.Handle("POST /api/users/{id}/mute", mw(muteUserHandler(app)))
+	mux.Handle("POST /api/muted_words", mw(createMutedWordHandler(app)))
+	mux.Handle("POST /api/conversations", mw(createConversationHandler(app)))
+	mux.Handle("POST /api/conversations/{id}/messages", mw(sendMessageHandler(app)))
+	mux.Handle("POST /api/conversations/{id}/read", mw(readConversationHandler(app)))
+	mux.Handle("POST /api/collections", mw(createCollectionHandler(app)))
+	mux.Handle("POST /api/collections/{id}/chirps", mw(addCollectionChirpHandler(app)))
+	mux.Handle("POST /api/media", mw(uploadMediaHandler(app)))
+	mux.Handle("POST /api/notifications/read", mw(readNotificationsHandler(app)))
 
 	mux.Handle("PUT /api/users", mw(updateUserHandler(app)))
+	mux.Handle("PATCH /api/users", mw(updateProfileHandler(app)))
 	mux.Handle("PATCH /api/chirps/{id}", mw(updateChirpHandler(app)))
 
+	mux.Handle("GET /api/drafts", mw(getDraftsHandler(app)))
+	mux.Handle("GET /api/drafts/{id}", mw(getDraftByIDHandler(app)))
+	mux.Handle("POST /api/drafts", mw(createDraftHandler(app)))
+	mux.Handle("POST /api/drafts/{id}/publish", mw(publishDraftHandler(app)))
+	mux.Handle("PUT /api/drafts/{id}", mw(updateDraftHandler(app)))
+	mux.Handle("PATCH /api/collections/{id}", mw(renameCollectionHandler(app)))
+	mux.Handle("DELETE /api/drafts/{id}", mw(deleteDraftByID(app)))
+	mux.Handle("DELETE /api/chirps/{id}/bookmark", mw(unbookmarkChirpHandler(app)))
+	mux.Handle("DELETE /api/chirps/{id}/pin", mw(unpinChirpHandler(app)))
+	mux.Handle("DELETE /api/users/{id}/follow", mw(unfollowUserHandler(app)))
+	mux.Handle("DELETE /api/users/{id}/block", mw(unblockUserHandler(app)))
+	mux.Handle("DELETE /api/users/{id}/mute", mw(unmuteUserHandler(app)))
+	mux.Handle("DELETE /api/muted_words/{id}", mw(deleteMutedWordByID(app)))
+	mux.Handle("DELETE /api/collections/{id}", mw(deleteCollectionByID(app)))
+	mux.Handle("DELETE /api/collections/{id}/chirps/{chirpID}", mw(removeCollectionChirpHandler(app)))
+
 	mux.Handle("DELETE /api/users/{id}", mw(deleteUserByID(app)))
 	mux.Handle("DELETE /api/chirps/{chirpID}", mw(deleteChirpByID(app)))
 
+	mux.Handle("GET /metrics", app.metrics.Handler())
 	mux.Handle("GET /admin/metrics", app.HandlerMetrics())
 	mux.Handle("POST /admin/reset", app.HandlerReset())
+	mux.Handle("GET /admin/export", exportHandler(app))
+	mux.Handle("POST /admin/import", importHandler(app))
+
+	// SIGINT and SIGTERM cancel ctx, which starts the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Go(func() { app.runPurger(ctx) })
+	workers.Go(func() { app.runPublisher(ctx) })
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           logging.Middleware(logger, app.metrics.Middleware(tracing.Middleware(mux))),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Shutdown doesn't wait for hijacked or long-lived responses to end
+	// by themselves, so streams are told to close
+	srv.RegisterOnShutdown(app.closeStreams)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Chirpy server start", "addr", "http://localhost:"+port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var failed bool
+	select {
+	case err = <-serveErr:
+		slog.Error("error serving", "err", err)
+		failed = true
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+	}
+	stop()
+
+	// /readyz fails from now on
+	app.shuttingDown.Store(true)
+	if !failed && cfg.ShutdownDelay > 0 {
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	srv := &http.Server{Addr: ":" + port, Handler: mux}
+	// stops accepting connections and waits for in-flight requests
+	if err = srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error draining requests", "err", err)
+		srv.Close()
+	}
 
-	log.Printf("Chirpy server start at http://localhost:%s", port)
-	log.Fatal(srv.ListenAndServe())
+	workers.Wait()
+
+	if err = shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("error flushing traces", "err", err)
+	}
+	if err = app.Close(); err != nil {
+		slog.Error("error closing database", "err", err)
+	}
+
+	slog.Info("server stopped")
+	if failed {
+		os.Exit(1)
+	}
 }
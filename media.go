@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+	"github.com/prchop/chirpysrv/internal/media"
+	"github.com/prchop/chirpysrv/internal/storage"
+)
+
+// maxAttachments is how many images a single chirp can carry.
+const maxAttachments = 4
+
+type MediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+func (app *App) newMediaResponse(attachment database.Attachment, now time.Time) MediaResponse {
+	return MediaResponse{
+		ID:           attachment.ID,
+		ContentType:  attachment.ContentType,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+		URL:          app.signer.URL(attachment.BlobKey, now),
+		ThumbnailURL: app.signer.URL(attachment.ThumbnailKey, now),
+	}
+}
+
+func uploadMediaHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		// leave some room for the multipart framing around the file
+		r.Body = http.MaxBytesReader(w, r.Body, app.config.MediaMaxBytes+64<<10)
+		defer r.Body.Close()
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error reading upload", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Missing file")
+			return
+		}
+		defer file.Close()
+
+		img, err := media.Process(file, app.config.MediaMaxBytes)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error processing upload", "err", err)
+			switch {
+			case errors.Is(err, media.ErrTooLarge):
+				responseWithError(w, http.StatusRequestEntityTooLarge, "Image is too large")
+			case errors.Is(err, media.ErrUnsupportedType):
+				responseWithError(w, http.StatusUnsupportedMediaType, "Only JPEG and PNG images are supported")
+			default:
+				responseWithError(w, http.StatusBadRequest, "Invalid image")
+			}
+			return
+		}
+
+		id := uuid.New()
+		blobKey := id.String() + img.Ext
+		thumbKey := id.String() + "_thumb" + img.Ext
+
+		if err = app.blobs.Put(r.Context(), blobKey, img.Data, img.ContentType); err != nil {
+			slog.ErrorContext(r.Context(), "error storing image", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// blobs without a row would never be cleaned up, so they are
+		// removed again when the upload fails past this point
+		cleanupCtx := context.WithoutCancel(r.Context())
+
+		if err = app.blobs.Put(r.Context(), thumbKey, img.Thumbnail, img.ContentType); err != nil {
+			slog.ErrorContext(r.Context(), "error storing thumbnail", "err", err)
+			app.deleteBlobKeys(cleanupCtx, blobKey)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		dbAttachment, err := app.db.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+			ID:           id,
+			UserID:       validID,
+			ContentType:  img.ContentType,
+			Width:        int32(img.Width),
+			Height:       int32(img.Height),
+			BlobKey:      blobKey,
+			ThumbnailKey: thumbKey,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating attachment", "err", err)
+			app.deleteBlobKeys(cleanupCtx, blobKey, thumbKey)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusCreated, app.newMediaResponse(dbAttachment, time.Now()))
+	})
+}
+
+func serveMediaHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		q := r.URL.Query()
+
+		expiresAt, err := app.signer.Verify(key, q.Get("expires"), q.Get("signature"), time.Now())
+		if err != nil {
+			responseWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		blob, err := app.blobs.Get(r.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error reading blob", "key", key, "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer blob.Close()
+
+		// blobs never change once written, so they can be cached for as
+		// long as the signed URL is valid
+		maxAge := int(time.Until(expiresAt).Seconds())
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge)+", immutable")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, blob)
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prchop/chirpysrv/sql/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// newMigrator returns a goose provider for the migrations built into the
+// binary. Up and down hold a Postgres advisory lock while they run, so
+// replicas migrating at the same time wait for each other.
+func newMigrator(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, schema.FS, goose.WithSessionLocker(locker))
+}
+
+// migrateOnStart applies the pending migrations before the server starts
+// serving. Replicas that wait for the lock find nothing left to apply.
+func migrateOnStart(ctx context.Context, db *sql.DB) error {
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	results, err := migrator.Up(ctx)
+	for _, res := range results {
+		slog.InfoContext(ctx, "applied migration",
+			"version", res.Source.Version, "duration", res.Duration)
+	}
+	return err
+}
+
+// runMigrate implements `chirpy migrate up|down|status|redo`.
+func runMigrate(ctx context.Context, cfg Config, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status|redo")
+	}
+
+	db, err := sql.Open(cfg.DBDriver, cfg.DBURI)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		printResults(w, results...)
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(w, "no migrations to apply")
+		}
+		return err
+	case "down":
+		res, err := migrator.Down(ctx)
+		printResults(w, res)
+		return err
+	case "redo":
+		// goose has no redo of its own, so the lock is taken twice and
+		// another replica could apply the migration in between
+		res, err := migrator.Down(ctx)
+		printResults(w, res)
+		if err != nil {
+			return err
+		}
+		res, err = migrator.UpByOne(ctx)
+		printResults(w, res)
+		return err
+	case "status":
+		return printStatus(ctx, migrator, w)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printResults(w io.Writer, results ...*goose.MigrationResult) {
+	for _, res := range results {
+		if res != nil {
+			fmt.Fprintln(w, res)
+		}
+	}
+}
+
+func printStatus(ctx context.Context, migrator *goose.Provider, w io.Writer) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "APPLIED AT\tMIGRATION")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", appliedAt, s.Source.Path)
+	}
+	return tw.Flush()
+}
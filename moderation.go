@@ -0,0 +1,81 @@
+package main
+
+import "strings"
+
+const maxChirpLength = 140
+
+var profaneWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+// tokenize splits a chirp body into the words moderation looks at.
+func tokenize(body string) []string {
+	return strings.Split(body, " ")
+}
+
+// normalizeToken folds a word so that matching is case-insensitive.
+func normalizeToken(word string) string {
+	return strings.ToLower(word)
+}
+
+// phraseTokens splits text into normalized words, dropping the empty ones
+// left by repeated spaces.
+func phraseTokens(text string) []string {
+	var tokens []string
+	for _, w := range tokenize(text) {
+		if w != "" {
+			tokens = append(tokens, normalizeToken(w))
+		}
+	}
+	return tokens
+}
+
+// containsPhrase reports whether the words of phrase appear in a row in
+// body. Plain words also match their hashtag, so muting "go" hides "#go",
+// but muting "#go" leaves "go" alone.
+func containsPhrase(body string, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+
+	words := phraseTokens(body)
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, p := range phrase {
+			w := words[i+j]
+			if w != p && (strings.HasPrefix(p, "#") || strings.TrimPrefix(w, "#") != p) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanChirpBody masks profane words in a chirp body.
+func cleanChirpBody(body string) string {
+	words := tokenize(body)
+	for i := range words {
+		for _, p := range profaneWords {
+			if normalizeToken(words[i]) == p {
+				words[i] = "****"
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// checkChirpBody validates a chirp body and returns it ready to be stored.
+// When the body is rejected, problem holds the message for the client.
+func checkChirpBody(body string) (cleaned, problem string) {
+	if len(body) == 0 {
+		return "", "Empty request body"
+	}
+
+	if len(body) > maxChirpLength {
+		return "", "Chirp is too long"
+	}
+
+	return cleanChirpBody(body), ""
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhraseTokens(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"Hello World", []string{"hello", "world"}},
+		{"  spaced   out  ", []string{"spaced", "out"}},
+		{"#GoLang rocks", []string{"#golang", "rocks"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got := phraseTokens(tc.input)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %q, want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestContainsPhrase(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		phrase string
+		want   bool
+	}{
+		{"word", "I love Go", "go", true},
+		{"case", "I LOVE GO", "Go", true},
+		{"part of a word", "gopher time", "go", false},
+		{"punctuation", "go!", "go", false},
+		{"word matches hashtag", "learning #go today", "go", true},
+		{"hashtag matches hashtag", "learning #Go today", "#go", true},
+		{"hashtag skips word", "learning go today", "#go", false},
+		{"phrase in a row", "the world cup final", "world cup", true},
+		{"phrase with extra spaces", "the world  cup final", "world   cup", true},
+		{"phrase out of order", "cup of the world", "world cup", false},
+		{"phrase split up", "world and cup", "world cup", false},
+		{"phrase at the end", "watching the world cup", "world cup", true},
+		{"phrase longer than body", "cup", "world cup", false},
+		{"phrase with hashtags", "#world #cup tonight", "world cup", true},
+		{"empty phrase", "anything", "", false},
+		{"empty body", "", "go", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := containsPhrase(tc.body, phraseTokens(tc.phrase))
+			if got != tc.want {
+				t.Errorf("containsPhrase(%q, %q): got: %t, want: %t", tc.body, tc.phrase, got, tc.want)
+			}
+		})
+	}
+}
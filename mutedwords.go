@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const (
+	mutedScopeTimeline      = "timeline"
+	mutedScopeNotifications = "notifications"
+	mutedScopeBoth          = "both"
+
+	maxMutedPhraseLength = 100
+)
+
+type MutedWordResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Phrase    string     `json:"phrase"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func newMutedWordResponse(word database.MutedWord) MutedWordResponse {
+	var expiresAt *time.Time
+	if word.ExpiresAt.Valid {
+		expiresAt = &word.ExpiresAt.Time
+	}
+
+	return MutedWordResponse{
+		ID:        word.ID,
+		CreatedAt: word.CreatedAt,
+		Phrase:    word.Phrase,
+		Scope:     word.Scope,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// wordFilter holds the phrases a user muted, already split into words,
+// for each place they apply to.
+type wordFilter struct {
+	timeline      [][]string
+	notifications [][]string
+}
+
+// loadWordFilter returns the unexpired muted words of viewer, which are
+// empty for anonymous requests.
+func (app *App) loadWordFilter(ctx context.Context, viewer uuid.UUID) (wordFilter, error) {
+	var f wordFilter
+	if viewer == uuid.Nil {
+		return f, nil
+	}
+
+	words, err := app.db.GetMutedWords(ctx, viewer)
+	if err != nil {
+		return wordFilter{}, err
+	}
+
+	for _, w := range words {
+		phrase := phraseTokens(w.Phrase)
+		if w.Scope != mutedScopeNotifications {
+			f.timeline = append(f.timeline, phrase)
+		}
+		if w.Scope != mutedScopeTimeline {
+			f.notifications = append(f.notifications, phrase)
+		}
+	}
+
+	return f, nil
+}
+
+func matchesAny(body string, phrases [][]string) bool {
+	for _, p := range phrases {
+		if containsPhrase(body, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// hidesInTimeline reports whether a chirp with body is kept out of the
+// user's timeline.
+func (f wordFilter) hidesInTimeline(body string) bool {
+	return matchesAny(body, f.timeline)
+}
+
+// hidesInNotifications reports whether notifications about a chirp with
+// body are kept from the user.
+func (f wordFilter) hidesInNotifications(body string) bool {
+	return matchesAny(body, f.notifications)
+}
+
+// filterTimeline returns the chirps that don't match a muted word.
+func (f wordFilter) filterTimeline(chirps []database.Chirp) []database.Chirp {
+	result := []database.Chirp{}
+	for _, c := range chirps {
+		if !f.hidesInTimeline(c.Body) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+type MutedWordRequest struct {
+	Phrase    string     `json:"phrase" required:"true"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func createMutedWordHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params MutedWordRequest
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		// stored the way chirps are tokenized, so matching stays consistent
+		phrase := strings.Join(phraseTokens(params.Phrase), " ")
+		if phrase == "" || len(phrase) > maxMutedPhraseLength {
+			responseWithError(w, http.StatusBadRequest, "Phrase must be between 1 and 100 characters")
+			return
+		}
+
+		switch params.Scope {
+		case "":
+			params.Scope = mutedScopeBoth
+		case mutedScopeTimeline, mutedScopeNotifications, mutedScopeBoth:
+		default:
+			responseWithError(w, http.StatusBadRequest, "scope must be timeline, notifications or both")
+			return
+		}
+
+		var expiresAt sql.NullTime
+		if params.ExpiresAt != nil {
+			if !params.ExpiresAt.After(time.Now()) {
+				responseWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+				return
+			}
+			// the column has no time zone, so the offset would be dropped
+			expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbWord, err := app.db.CreateMutedWord(r.Context(), database.CreateMutedWordParams{
+			UserID:    validID,
+			Phrase:    phrase,
+			Scope:     params.Scope,
+			ExpiresAt: expiresAt,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			responseWithError(w, http.StatusConflict, "Phrase is already muted")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error creating muted word", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusCreated, newMutedWordResponse(dbWord))
+	})
+}
+
+func getMutedWordsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbWords, err := app.db.GetMutedWords(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving muted words", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		words := make([]MutedWordResponse, len(dbWords))
+		for i, mw := range dbWords {
+			words[i] = newMutedWordResponse(mw)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			MutedWords []MutedWordResponse `json:"muted_words"`
+		}{
+			MutedWords: words,
+		})
+	})
+}
+
+func deleteMutedWordByID(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wordID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing muted word id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		n, err := app.db.DeleteMutedWord(r.Context(), database.DeleteMutedWordParams{
+			ID:     wordID,
+			UserID: validID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error deleting muted word", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const (
+	notificationQuote = "quote"
+
+	maxNotifications = 50
+)
+
+type NotificationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Kind      string     `json:"kind"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	Read      bool       `json:"read"`
+}
+
+func newNotificationResponse(n database.Notification) NotificationResponse {
+	var chirpID *uuid.UUID
+	if n.ChirpID.Valid {
+		chirpID = &n.ChirpID.UUID
+	}
+
+	return NotificationResponse{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Kind:      n.Kind,
+		ActorID:   n.ActorID,
+		ChirpID:   chirpID,
+		Read:      n.ReadAt.Valid,
+	}
+}
+
+// notifyQuote tells the author of the quoted chirp that chirp quotes it.
+// It is called once the quote is published.
+func notifyQuote(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if !chirp.QuoteOf.Valid {
+		return nil
+	}
+
+	quoted, err := q.GetChirpByID(ctx, chirp.QuoteOf.UUID)
+	if err != nil {
+		// the quoted chirp is gone, there is nobody to notify
+		return nil
+	}
+
+	if quoted.UserID == chirp.UserID {
+		return nil
+	}
+
+	blocked, err := q.IsBlocked(ctx, database.IsBlockedParams{
+		BlockerID: quoted.UserID,
+		BlockedID: chirp.UserID,
+	})
+	if err != nil || blocked {
+		return err
+	}
+
+	return q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  quoted.UserID,
+		ActorID: chirp.UserID,
+		Kind:    notificationQuote,
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+}
+
+// filterNotifications drops the notifications about chirps that match
+// one of the user's muted words.
+func (app *App) filterNotifications(ctx context.Context, notifications []database.Notification,
+	user uuid.UUID) ([]database.Notification, error) {
+	words, err := app.loadWordFilter(ctx, user)
+	if err != nil || len(words.notifications) == 0 {
+		return notifications, err
+	}
+
+	var chirpIDs []uuid.UUID
+	for _, n := range notifications {
+		if n.ChirpID.Valid {
+			chirpIDs = append(chirpIDs, n.ChirpID.UUID)
+		}
+	}
+
+	chirps, err := app.db.GetChirpsByIDs(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	muted := make(map[uuid.UUID]bool, len(chirps))
+	for _, c := range chirps {
+		muted[c.ID] = words.hidesInNotifications(c.Body)
+	}
+
+	result := []database.Notification{}
+	for _, n := range notifications {
+		if !n.ChirpID.Valid || !muted[n.ChirpID.UUID] {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+func getNotificationsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbNotifications, err := app.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+			UserID: validID,
+			Limit:  maxNotifications,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving notifications", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		dbNotifications, err = app.filterNotifications(r.Context(), dbNotifications, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error filtering notifications", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		notifications := make([]NotificationResponse, len(dbNotifications))
+		for i, n := range dbNotifications {
+			notifications[i] = newNotificationResponse(n)
+		}
+
+		responseWithJSON(w, http.StatusOK, struct {
+			Notifications []NotificationResponse `json:"notifications"`
+		}{
+			Notifications: notifications,
+		})
+	})
+}
+
+func readNotificationsHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		if _, err = app.db.MarkNotificationsRead(r.Context(), validID); err != nil {
+			slog.ErrorContext(r.Context(), "error marking notifications read", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
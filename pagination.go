@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// page holds the keyset pagination parameters of a request. Rows are
+// returned newest first, strictly before (BeforeTime, BeforeID).
+type page struct {
+	BeforeTime time.Time
+	BeforeID   uuid.UUID
+	Size       int32
+}
+
+// parsePage reads the cursor and limit query parameters. Without a cursor
+// the page starts at the newest row.
+func parsePage(r *http.Request) (page, error) {
+	p := page{
+		BeforeTime: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+		BeforeID:   uuid.Max,
+		Size:       defaultPageSize,
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return page{}, errors.New("invalid limit")
+		}
+		p.Size = int32(min(n, maxPageSize))
+	}
+
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		return p, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return page{}, errInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return page{}, errInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return page{}, errInvalidCursor
+	}
+
+	p.BeforeID, err = uuid.Parse(id)
+	if err != nil {
+		return page{}, errInvalidCursor
+	}
+	p.BeforeTime = time.Unix(0, n).UTC()
+
+	return p, nil
+}
+
+// nextCursor returns the cursor of the page after the one ending at
+// (t, id), or "" when the current page was not full.
+func (p page) nextCursor(rows int, t time.Time, id uuid.UUID) string {
+	if rows < int(p.Size) {
+		return ""
+	}
+	raw := strconv.FormatInt(t.UnixNano(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
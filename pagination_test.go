@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParsePage(t *testing.T) {
+	id := uuid.MustParse("c5b48091-f192-479b-8b18-b5db547c1eff")
+	at := time.Date(2026, 10, 18, 12, 30, 0, 123456789, time.UTC)
+	full := page{Size: 2}
+	cursor := full.nextCursor(2, at, id)
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name     string
+		query    url.Values
+		wantTime time.Time
+		wantID   uuid.UUID
+		wantSize int32
+		wantErr  error
+	}{
+		{name: "first page", query: url.Values{},
+			wantTime: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC), wantID: uuid.Max, wantSize: defaultPageSize},
+		{name: "limit", query: url.Values{"limit": {"5"}},
+			wantTime: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC), wantID: uuid.Max, wantSize: 5},
+		{name: "limit capped", query: url.Values{"limit": {"1000"}},
+			wantTime: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC), wantID: uuid.Max, wantSize: maxPageSize},
+		{name: "cursor", query: url.Values{"cursor": {cursor}},
+			wantTime: at, wantID: id, wantSize: defaultPageSize},
+		{name: "zero limit", query: url.Values{"limit": {"0"}}, wantErr: errors.New("invalid limit")},
+		{name: "negative limit", query: url.Values{"limit": {"-1"}}, wantErr: errors.New("invalid limit")},
+		{name: "limit not a number", query: url.Values{"limit": {"ten"}}, wantErr: errors.New("invalid limit")},
+		{name: "cursor not base64", query: url.Values{"cursor": {"not base64!"}}, wantErr: errInvalidCursor},
+		{name: "cursor padded", query: url.Values{"cursor": {base64.URLEncoding.EncodeToString([]byte("1:" + id.String()))}}, wantErr: errInvalidCursor},
+		{name: "cursor without id", query: url.Values{"cursor": {encode("1700000000")}}, wantErr: errInvalidCursor},
+		{name: "cursor bad time", query: url.Values{"cursor": {encode("yesterday:" + id.String())}}, wantErr: errInvalidCursor},
+		{name: "cursor time overflow", query: url.Values{"cursor": {encode("99999999999999999999:" + id.String())}}, wantErr: errInvalidCursor},
+		{name: "cursor bad id", query: url.Values{"cursor": {encode("1700000000:not-a-uuid")}}, wantErr: errInvalidCursor},
+		{name: "cursor tampered", query: url.Values{"cursor": {cursor[:len(cursor)-4]}}, wantErr: errInvalidCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/chirps?"+tc.query.Encode(), nil)
+			got, err := parsePage(r)
+			if tc.wantErr != nil {
+				if err == nil || err.Error() != tc.wantErr.Error() {
+					t.Fatalf("got err %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.BeforeTime.Equal(tc.wantTime) || got.BeforeID != tc.wantID || got.Size != tc.wantSize {
+				t.Errorf("got: %+v, want: %s %s %d", got, tc.wantTime, tc.wantID, tc.wantSize)
+			}
+		})
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	id := uuid.MustParse("c49740d1-f27e-4a89-90cb-8472b682585c")
+	at := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rows  int
+		size  int32
+		empty bool
+	}{
+		{"full page", 20, 20, false},
+		{"short page", 19, 20, true},
+		{"no rows", 0, 20, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := page{Size: tc.size}.nextCursor(tc.rows, at, id)
+			if (got == "") != tc.empty {
+				t.Errorf("got: %q, want empty %t", got, tc.empty)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// pinLimit returns how many chirps user can pin, which depends on their
+// plan.
+func (app *App) pinLimit(user database.User) int64 {
+	if user.IsChirpyRed {
+		return int64(app.config.PinLimitRed)
+	}
+	return int64(app.config.PinLimit)
+}
+
+func pinChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		// lock the user so concurrent pins can't go over the limit
+		dbUser, err := qtx.GetUserByIDForUpdate(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbChirp, err := qtx.GetChirpByID(r.Context(), chirpID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		if dbChirp.UserID != dbUser.ID {
+			responseWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		count, err := qtx.CountPinnedChirps(r.Context(), dbUser.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error counting pinned chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		n, err := qtx.PinChirp(r.Context(), database.PinChirpParams{
+			UserID:  dbUser.ID,
+			ChirpID: dbChirp.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error pinning chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// pinning an already pinned chirp is a no-op, so only new pins are
+		// held to the limit
+		if limit := app.pinLimit(dbUser); n > 0 && count >= limit {
+			responseWithError(w, http.StatusConflict,
+				"You can pin at most "+strconv.FormatInt(limit, 10)+" chirps")
+			return
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing pin", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
+
+func unpinChirpHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		n, err := app.db.UnpinChirp(r.Context(), database.UnpinChirpParams{
+			UserID:  validID,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error unpinning chirp", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		responseWithNoContent(w, http.StatusNoContent)
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const (
+	minPollOptions     = 2
+	maxPollOptions     = 4
+	maxPollOptionLabel = 25
+)
+
+type PollRequest struct {
+	Options     []string  `json:"options"`
+	ClosesAt    time.Time `json:"closes_at"`
+	HideResults bool      `json:"hide_results"`
+}
+
+// checkPoll validates a poll sent along with a new chirp that becomes
+// visible at publishAt. It returns a message for the client when the
+// poll is rejected.
+func checkPoll(poll PollRequest, publishAt time.Time) string {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return "A poll needs between 2 and 4 options"
+	}
+
+	for _, o := range poll.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || len(o) > maxPollOptionLabel {
+			return "Poll options must be between 1 and 25 characters"
+		}
+	}
+
+	if !poll.ClosesAt.After(publishAt) {
+		return "closes_at must be after the chirp is published"
+	}
+
+	return ""
+}
+
+// createPoll stores a poll for a chirp that was just created inside the
+// same transaction. closes_at has no time zone, so it's stored in UTC.
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, poll PollRequest) error {
+	_, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:     chirpID,
+		ClosesAt:    poll.ClosesAt.UTC(),
+		HideResults: poll.HideResults,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, label := range poll.Options {
+		_, err = qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Label:    strings.TrimSpace(label),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type PollOptionResponse struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label"`
+	Votes *int64    `json:"votes"`
+}
+
+type PollResponse struct {
+	ClosesAt      time.Time            `json:"closes_at"`
+	Closed        bool                 `json:"closed"`
+	HideResults   bool                 `json:"hide_results"`
+	TotalVotes    *int64               `json:"total_votes"`
+	VotedOptionID *uuid.UUID           `json:"voted_option_id"`
+	Options       []PollOptionResponse `json:"options"`
+}
+
+// loadPolls attaches poll results to the chirps in resp. Vote counts of
+// polls with hidden results are left out until the poll closes, except
+// for the chirp's author.
+func (app *App) loadPolls(ctx context.Context, resp []ChirpResponse, ids []uuid.UUID,
+	index map[uuid.UUID]int, viewer uuid.UUID, now time.Time) error {
+	polls, err := app.db.GetPollsByChirpIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(polls) == 0 {
+		return nil
+	}
+
+	for _, p := range polls {
+		i := index[p.ChirpID]
+		closed := !now.Before(p.ClosesAt)
+		showResults := closed || !p.HideResults || resp[i].UserID == viewer
+
+		poll := &PollResponse{
+			ClosesAt:    p.ClosesAt,
+			Closed:      closed,
+			HideResults: p.HideResults,
+			Options:     []PollOptionResponse{},
+		}
+		if showResults {
+			poll.TotalVotes = new(int64)
+		}
+		resp[i].Poll = poll
+	}
+
+	results, err := app.db.GetPollResultsByChirpIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range results {
+		poll := resp[index[o.ChirpID]].Poll
+		option := PollOptionResponse{ID: o.ID, Label: o.Label}
+		if poll.TotalVotes != nil {
+			option.Votes = &o.Votes
+			*poll.TotalVotes += o.Votes
+		}
+		poll.Options = append(poll.Options, option)
+	}
+
+	if viewer == uuid.Nil {
+		return nil
+	}
+
+	votes, err := app.db.GetUserPollVotes(ctx, database.GetUserPollVotesParams{
+		UserID:   viewer,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range votes {
+		resp[index[v.ChirpID]].Poll.VotedOptionID = &v.OptionID
+	}
+
+	return nil
+}
+
+func votePollHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type requestVote struct {
+			OptionID uuid.UUID `json:"option_id"`
+		}
+		var params requestVote
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		chirpID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing chirp id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbChirp, err := app.getVisibleChirp(r.Context(), chirpID, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirp", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		dbPoll, err := app.db.GetPollByChirpID(r.Context(), dbChirp.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving poll", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		if !time.Now().Before(dbPoll.ClosesAt) {
+			responseWithError(w, http.StatusConflict, "Poll is closed")
+			return
+		}
+
+		// the primary key on (chirp_id, user_id) keeps it to one vote
+		n, err := app.db.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+			ChirpID:  dbPoll.ChirpID,
+			UserID:   validID,
+			OptionID: params.OptionID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error voting", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Invalid option")
+			return
+		}
+		if n == 0 {
+			responseWithError(w, http.StatusConflict, "Already voted")
+			return
+		}
+
+		votedChirp, err := app.chirpResponse(r.Context(), dbChirp, validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirp", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, votedChirp)
+	})
+}
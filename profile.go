@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const (
+	maxBioLength      = 160
+	maxLocationLength = 30
+	maxWebsiteLength  = 100
+)
+
+// publicUserResponses builds the public representation of users along
+// with their avatars, banners and counts.
+func (app *App) publicUserResponses(ctx context.Context, users []database.User) ([]PublicUserResponse, error) {
+	resp := make([]PublicUserResponse, len(users))
+	if len(users) == 0 {
+		return resp, nil
+	}
+
+	ids := make([]uuid.UUID, len(users))
+	index := make(map[uuid.UUID]int, len(users))
+	var mediaIDs []uuid.UUID
+	for i, u := range users {
+		resp[i] = newPublicUserResponse(u)
+		ids[i] = u.ID
+		index[u.ID] = i
+		if u.AvatarID.Valid {
+			mediaIDs = append(mediaIDs, u.AvatarID.UUID)
+		}
+		if u.BannerID.Valid {
+			mediaIDs = append(mediaIDs, u.BannerID.UUID)
+		}
+	}
+
+	stats, err := app.db.GetUserStats(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, st := range stats {
+		i := index[st.ID]
+		resp[i].FollowerCount = st.FollowerCount
+		resp[i].FollowingCount = st.FollowingCount
+		resp[i].ChirpCount = st.ChirpCount
+	}
+
+	if len(mediaIDs) == 0 {
+		return resp, nil
+	}
+
+	attachments, err := app.db.GetAttachmentsByIDs(ctx, mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	media := make(map[uuid.UUID]MediaResponse, len(attachments))
+	for _, a := range attachments {
+		media[a.ID] = app.newMediaResponse(a, now)
+	}
+
+	for i, u := range users {
+		if m, ok := media[u.AvatarID.UUID]; ok && u.AvatarID.Valid {
+			resp[i].Avatar = &m
+		}
+		if m, ok := media[u.BannerID.UUID]; ok && u.BannerID.Valid {
+			resp[i].Banner = &m
+		}
+	}
+
+	return resp, nil
+}
+
+// userResponse builds the owner-only representation of user.
+func (app *App) userResponse(ctx context.Context, user database.User) (UserResponse, error) {
+	public, err := app.publicUserResponses(ctx, []database.User{user})
+	if err != nil {
+		return UserResponse{}, err
+	}
+
+	resp := newUserResponse(user)
+	resp.PublicUserResponse = public[0]
+	return resp, nil
+}
+
+type ProfileRequest struct {
+	Bio      *string `json:"bio"`
+	Location *string `json:"location"`
+	Website  *string `json:"website"`
+	// AvatarID and BannerID take the id of an uploaded image, an empty
+	// string removes it
+	AvatarID *string `json:"avatar_id"`
+	BannerID *string `json:"banner_id"`
+	// DMPolicy restricts who can start conversations with the user
+	DMPolicy *string `json:"dm_policy"`
+	// Protected turns follows into requests the user has to approve
+	Protected *bool `json:"protected"`
+}
+
+// checkProfile validates the profile fields of user after an update. It
+// returns a message for the client when they are rejected.
+func checkProfile(user database.User) string {
+	if utf8.RuneCountInString(user.Bio) > maxBioLength {
+		return "Bio is too long"
+	}
+
+	if utf8.RuneCountInString(user.Location) > maxLocationLength {
+		return "Location is too long"
+	}
+
+	if user.DmPolicy != dmPolicyEveryone && user.DmPolicy != dmPolicyFollowing {
+		return "dm_policy must be everyone or following"
+	}
+
+	if user.Website == "" {
+		return ""
+	}
+	if len(user.Website) > maxWebsiteLength {
+		return "Website is too long"
+	}
+	u, err := url.Parse(user.Website)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "Website must be an http or https URL"
+	}
+
+	return ""
+}
+
+// profileImage resolves the image id sent for an avatar or banner. The
+// image must have been uploaded by user.
+func (app *App) profileImage(ctx context.Context, raw *string, current uuid.NullUUID,
+	user uuid.UUID) (uuid.NullUUID, bool) {
+	if raw == nil {
+		return current, true
+	}
+	if *raw == "" {
+		return uuid.NullUUID{}, true
+	}
+
+	id, err := uuid.Parse(*raw)
+	if err != nil {
+		return uuid.NullUUID{}, false
+	}
+
+	attachment, err := app.db.GetAttachmentForUser(ctx, database.GetAttachmentForUserParams{
+		ID:     id,
+		UserID: user,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error retrieving attachment", "err", err)
+		return uuid.NullUUID{}, false
+	}
+
+	return uuid.NullUUID{UUID: attachment.ID, Valid: true}, true
+}
+
+func updateProfileHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params ProfileRequest
+		defer r.Body.Close()
+
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&params); err != nil {
+			slog.WarnContext(r.Context(), "error decoding", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		dbUser, err := app.db.GetUserByID(r.Context(), validID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		if params.Bio != nil {
+			dbUser.Bio = strings.TrimSpace(*params.Bio)
+		}
+		if params.Location != nil {
+			dbUser.Location = strings.TrimSpace(*params.Location)
+		}
+		if params.Website != nil {
+			dbUser.Website = strings.TrimSpace(*params.Website)
+		}
+		if params.DMPolicy != nil {
+			dbUser.DmPolicy = *params.DMPolicy
+		}
+		if params.Protected != nil {
+			dbUser.Protected = *params.Protected
+		}
+
+		if problem := checkProfile(dbUser); problem != "" {
+			responseWithError(w, http.StatusBadRequest, problem)
+			return
+		}
+
+		avatarID, ok := app.profileImage(r.Context(), params.AvatarID, dbUser.AvatarID, dbUser.ID)
+		if !ok {
+			responseWithError(w, http.StatusBadRequest, "Invalid avatar")
+			return
+		}
+
+		bannerID, ok := app.profileImage(r.Context(), params.BannerID, dbUser.BannerID, dbUser.ID)
+		if !ok {
+			responseWithError(w, http.StatusBadRequest, "Invalid banner")
+			return
+		}
+
+		tx, err := app.conn.BeginTx(r.Context(), nil)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error starting transaction", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		defer tx.Rollback()
+		qtx := app.txQueries(tx)
+
+		dbUser, err = qtx.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+			Bio:       dbUser.Bio,
+			Location:  dbUser.Location,
+			Website:   dbUser.Website,
+			AvatarID:  avatarID,
+			BannerID:  bannerID,
+			DmPolicy:  dbUser.DmPolicy,
+			Protected: dbUser.Protected,
+			ID:        dbUser.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error updating profile", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		// a public account has no pending requests, so going public lets
+		// everyone who asked in
+		if !dbUser.Protected {
+			if _, err = qtx.ApproveFollowRequests(r.Context(), dbUser.ID); err != nil {
+				slog.ErrorContext(r.Context(), "error approving follow requests", "err", err)
+				responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "error committing profile", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		updatedUser, err := app.userResponse(r.Context(), dbUser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading user", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		responseWithJSON(w, http.StatusOK, updatedUser)
+	})
+}
+
+type ProfileResponse struct {
+	User         PublicUserResponse `json:"user"`
+	PinnedChirps []ChirpResponse    `json:"pinned_chirps"`
+	Chirps       []ChirpResponse    `json:"chirps"`
+	NextCursor   string             `json:"next_cursor"`
+}
+
+func getUserProfileHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "error parsing user id", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		p, err := parsePage(r)
+		if err != nil {
+			responseWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dbUser, err := app.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving user", "err", err)
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		viewer := app.viewerID(r)
+		rel, err := app.loadRelations(r.Context(), viewer, []uuid.UUID{dbUser.ID})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading relations", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		if rel.blocked[dbUser.ID] {
+			responseWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		pinnedRows, err := app.db.GetPinnedChirps(r.Context(), dbUser.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving pinned chirps", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		dbPinned := make([]database.Chirp, len(pinnedRows))
+		for i, row := range pinnedRows {
+			dbPinned[i] = row.Chirp
+		}
+
+		dbChirps, err := app.db.GetUserChirps(r.Context(), database.GetUserChirpsParams{
+			UserID:     dbUser.ID,
+			BeforeTime: p.BeforeTime,
+			BeforeID:   p.BeforeID,
+			PageSize:   p.Size,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving chirps", "err", err)
+			responseWithError(w, http.StatusBadRequest, "Something went wrong")
+			return
+		}
+
+		pinned, err := app.chirpResponses(r.Context(), filterChirps(dbPinned, rel.canSee), viewer)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		// the cursor follows the unfiltered page, so hidden chirps don't
+		// cut pagination short
+		chirps, err := app.chirpResponses(r.Context(), filterChirps(dbChirps, rel.canSee), viewer)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading chirps", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		users, err := app.publicUserResponses(r.Context(), []database.User{dbUser})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error loading user", "err", err)
+			responseWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		var next string
+		if len(dbChirps) > 0 {
+			last := dbChirps[len(dbChirps)-1]
+			next = p.nextCursor(len(dbChirps), last.CreatedAt, last.ID)
+		}
+
+		responseWithJSON(w, http.StatusOK, ProfileResponse{
+			User:         users[0],
+			PinnedChirps: pinned,
+			Chirps:       chirps,
+			NextCursor:   next,
+		})
+	})
+}
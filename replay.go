@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/prchop/chirpysrv/internal/replay"
+)
+
+// varFlags collects repeated -var name=value flags.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	return fmt.Sprint(map[string]string(v))
+}
+
+func (v varFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return errors.New("want name=value")
+	}
+	v[name] = value
+	return nil
+}
+
+// runReplay implements `replay [flags] <file>`. It fails when any
+// response didn't match.
+func runReplay(ctx context.Context, cfg Config, args []string, stdin io.Reader, stdout io.Writer) error {
+	vars := varFlags{}
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	baseURL := fs.String("url", "http://localhost:"+cfg.Port, "server to replay against")
+	concurrency := fs.Int("c", 1, "workers running the script side by side")
+	iterations := fs.Int("n", 1, "times each worker runs the script")
+	rate := fs.Float64("rate", 0, "requests per second across all workers, 0 for no limit")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of a single request")
+	fs.Var(vars, "var", "set a variable, as `name=value` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: replay [flags] <file>, - for stdin")
+	}
+	// written so NaN fails too
+	if *rate != 0 && !(*rate >= replay.MinRate && *rate <= replay.MaxRate) {
+		return fmt.Errorf("-rate must be 0 or between %g and %g", replay.MinRate, replay.MaxRate)
+	}
+
+	r := stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	reqs, err := replay.Load(r)
+	if err != nil {
+		return err
+	}
+
+	// an interrupted run still reports what it got through
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	report := replay.Run(ctx, reqs, replay.Options{
+		BaseURL:     *baseURL,
+		Concurrency: *concurrency,
+		Iterations:  *iterations,
+		Rate:        *rate,
+		Vars:        vars,
+		Client:      &http.Client{Timeout: *timeout},
+	})
+	if err = report.Write(stdout); err != nil {
+		return err
+	}
+
+	if !report.Passed() {
+		return fmt.Errorf("%d of %d requests failed", report.Failed, report.Requests)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (
+  id,
+  created_at,
+  user_id,
+  content_type,
+  width,
+  height,
+  blob_key,
+  thumbnail_key
+)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: AttachToChirp :one
+UPDATE attachments SET (chirp_id, position) = ($1, $2)
+WHERE id = $3
+  AND user_id = $4
+  AND chirp_id IS NULL
+RETURNING *;
+
+-- name: GetAttachmentsByChirpIDs :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position ASC;
+
+-- name: GetAttachmentsByIDs :many
+SELECT * FROM attachments
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetAttachmentForUser :one
+SELECT * FROM attachments
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: DeleteOrphanAttachments :many
+-- Uploads that were never attached, or whose chirp was purged. Avatars
+-- and banners are kept while a profile uses them.
+DELETE FROM attachments
+WHERE chirp_id IS NULL
+  AND created_at < $1
+  AND id NOT IN (SELECT avatar_id FROM users WHERE avatar_id IS NOT NULL)
+  AND id NOT IN (SELECT banner_id FROM users WHERE banner_id IS NOT NULL)
+RETURNING *;
+
+-- name: DeletePurgedUserAttachments :many
+-- Attachments of the users PurgeDeletedUsers is about to remove, so their
+-- blobs can be deleted too. The cascade would drop the rows silently.
+DELETE FROM attachments
+WHERE user_id IN (SELECT id FROM users WHERE deleted_at < $1)
+RETURNING *;
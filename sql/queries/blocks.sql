@@ -0,0 +1,60 @@
+-- name: CreateBlock :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1
+  AND blocked_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT u.* FROM blocks b
+JOIN users u ON u.id = b.blocked_id
+WHERE b.blocker_id = $1
+  AND u.deleted_at IS NULL
+ORDER BY b.created_at DESC;
+
+-- name: GetBlockedUserIDs :many
+-- Users on either side of a block with the given user.
+SELECT blocked_id FROM blocks
+WHERE blocker_id = $1
+UNION
+SELECT blocker_id FROM blocks
+WHERE blocked_id = $1;
+
+-- name: IsBlocked :one
+SELECT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocker_id = $1 AND blocked_id = $2)
+     OR (blocker_id = $2 AND blocked_id = $1)
+);
+
+-- name: HasBlockAmong :one
+-- Whether any two of the given users block each other.
+SELECT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE blocker_id = ANY(sqlc.arg(ids)::uuid[])
+    AND blocked_id = ANY(sqlc.arg(ids)::uuid[])
+);
+
+-- name: CreateMute :execrows
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = $1
+  AND muted_id = $2;
+
+-- name: GetMutedUsers :many
+SELECT u.* FROM mutes m
+JOIN users u ON u.id = m.muted_id
+WHERE m.muter_id = $1
+  AND u.deleted_at IS NULL
+ORDER BY m.created_at DESC;
+
+-- name: GetMutedUserIDs :many
+SELECT muted_id FROM mutes
+WHERE muter_id = $1;
@@ -0,0 +1,28 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1
+  AND chirp_id = $2;
+
+-- name: GetBookmarkedChirps :many
+-- Keyset pagination, newest bookmark first. Pass the created_at and
+-- chirp_id of the last row of the previous page.
+SELECT sqlc.embed(c), b.created_at AS bookmarked_at
+FROM bookmarks b
+JOIN chirps c ON c.id = b.chirp_id
+WHERE b.user_id = sqlc.arg(user_id)
+  AND (b.created_at, b.chirp_id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+  AND c.published
+  AND c.deleted_at IS NULL
+  AND c.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+ORDER BY b.created_at DESC, b.chirp_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetBookmarkedChirpIDs :many
+SELECT chirp_id FROM bookmarks
+WHERE user_id = $1
+  AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
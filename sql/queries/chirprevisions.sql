@@ -0,0 +1,11 @@
+-- name: CreateChirpRevision :one
+INSERT INTO chirp_revisions (id, created_at, chirp_id, body)
+SELECT gen_random_uuid(), updated_at, id, body
+FROM chirps
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at ASC;
@@ -1,25 +1,147 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, visibility)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+RETURNING *;
+
+-- name: CreateScheduledChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, visibility, publish_at, published)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, FALSE)
 RETURNING *;
 
 -- name: UpdateChirp :one
-UPDATE chirps SET (updated_at, body) = (NOW(), $1)
+UPDATE chirps SET (updated_at, edited_at, revision_count, body) =
+  (NOW(), NOW(), revision_count + 1, $1)
 WHERE id = $2
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateScheduledChirp :one
+UPDATE chirps SET (updated_at, body, publish_at) = (NOW(), $1, $2)
+WHERE id = $3
+  AND NOT published
+  AND deleted_at IS NULL
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
+WHERE published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
 ORDER BY user_id ASC;
 
+-- name: GetUserChirps :many
+-- Keyset pagination, newest first. Pass the created_at and id of the
+-- last row of the previous page.
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at, id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+  AND published
+  AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
 -- name: GetChirpByID :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = $1
+  AND published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL);
 
--- name: DeleteChirpByID :one
-DELETE FROM chirps
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+  AND published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL);
+
+-- name: GetQuotesOfChirp :many
+SELECT * FROM chirps
+WHERE quote_of = $1
+  AND published
+  AND deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+ORDER BY created_at DESC;
+
+-- name: GetChirpByIDForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+  AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: GetChirpByIDForAuthor :one
+SELECT * FROM chirps
 WHERE id = $1
+  AND (published OR user_id = $2)
+  AND deleted_at IS NULL;
+
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+  AND NOT published
+  AND deleted_at IS NULL
+ORDER BY publish_at ASC;
+
+-- name: PublishDueChirps :many
+-- Rows locked by another instance are skipped, so several publishers can
+-- run at once without publishing the same chirp twice.
+UPDATE chirps SET (created_at, updated_at, published) = (publish_at, NOW(), TRUE)
+WHERE id IN (
+  SELECT id FROM chirps
+  WHERE NOT published
+    AND publish_at <= NOW()
+    AND deleted_at IS NULL
+  ORDER BY publish_at ASC
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: SoftDeleteChirpByID :one
+UPDATE chirps SET deleted_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
 RETURNING *;
 
+-- name: RestoreChirpByID :one
+UPDATE chirps SET (updated_at, deleted_at) = (NOW(), NULL)
+WHERE id = $1
+  AND user_id = $2
+  AND deleted_at > $3
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1;
+
 -- name: DeleteAllChirps :exec
 DELETE FROM chirps;
+
+-- name: ExportChirps :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+  AND (created_at, id) > (sqlc.arg(after_time)::timestamp, sqlc.arg(after_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ImportChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, edited_at, publish_at, published, quote_of, visibility)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ImportChirpOverwrite :one
+-- Returns whether the chirp was inserted rather than updated.
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, edited_at, publish_at, published, quote_of, visibility)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (id) DO UPDATE SET
+  created_at = EXCLUDED.created_at,
+  updated_at = EXCLUDED.updated_at,
+  body = EXCLUDED.body,
+  user_id = EXCLUDED.user_id,
+  edited_at = EXCLUDED.edited_at,
+  publish_at = EXCLUDED.publish_at,
+  published = EXCLUDED.published,
+  quote_of = EXCLUDED.quote_of,
+  visibility = EXCLUDED.visibility,
+  deleted_at = NULL
+RETURNING (xmax = 0) AS inserted;
@@ -0,0 +1,50 @@
+-- name: CreateCollection :one
+INSERT INTO collections (id, created_at, updated_at, user_id, name)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: GetCollections :many
+SELECT * FROM collections
+WHERE user_id = $1
+ORDER BY name ASC;
+
+-- name: GetCollectionByID :one
+SELECT * FROM collections
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: RenameCollection :one
+UPDATE collections SET (updated_at, name) = (NOW(), $1)
+WHERE id = $2
+  AND user_id = $3
+RETURNING *;
+
+-- name: DeleteCollection :one
+DELETE FROM collections
+WHERE id = $1
+  AND user_id = $2
+RETURNING *;
+
+-- name: AddChirpToCollection :exec
+INSERT INTO collection_chirps (collection_id, user_id, chirp_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (collection_id, chirp_id) DO NOTHING;
+
+-- name: RemoveChirpFromCollection :execrows
+DELETE FROM collection_chirps
+WHERE collection_id = $1
+  AND user_id = $2
+  AND chirp_id = $3;
+
+-- name: GetCollectionChirps :many
+SELECT sqlc.embed(c), cc.created_at AS filed_at
+FROM collection_chirps cc
+JOIN chirps c ON c.id = cc.chirp_id
+WHERE cc.collection_id = sqlc.arg(collection_id)
+  AND cc.user_id = sqlc.arg(user_id)
+  AND (cc.created_at, cc.chirp_id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+  AND c.published
+  AND c.deleted_at IS NULL
+  AND c.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+ORDER BY cc.created_at DESC, cc.chirp_id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,74 @@
+-- name: CreateConversation :one
+-- A one-to-one conversation that already exists is returned as is.
+INSERT INTO conversations (id, created_at, updated_at, direct_key, name)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (conversation_id, user_id) DO NOTHING;
+
+-- name: GetConversationForUser :one
+SELECT c.* FROM conversations c
+JOIN conversation_participants p ON p.conversation_id = c.id
+WHERE c.id = $1
+  AND p.user_id = $2;
+
+-- name: GetConversations :many
+-- Keyset pagination, most recently active first. Pass the updated_at and
+-- id of the last row of the previous page.
+SELECT c.* FROM conversations c
+JOIN conversation_participants p ON p.conversation_id = c.id
+WHERE p.user_id = sqlc.arg(user_id)
+  AND (c.updated_at, c.id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY c.updated_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetConversationParticipants :many
+-- Every participant with the number of messages from others they
+-- haven't read yet, leaving out senders blocked either way as their
+-- messages are hidden.
+SELECT
+  p.conversation_id,
+  p.user_id,
+  p.joined_at,
+  p.last_read_at,
+  (
+    SELECT COUNT(*) FROM messages m
+    WHERE m.conversation_id = p.conversation_id
+      AND m.sender_id <> p.user_id
+      AND (p.last_read_at IS NULL OR m.created_at > p.last_read_at)
+      AND NOT EXISTS (
+        SELECT 1 FROM blocks b
+        WHERE (b.blocker_id = p.user_id AND b.blocked_id = m.sender_id)
+           OR (b.blocker_id = m.sender_id AND b.blocked_id = p.user_id)
+      )
+  ) AS unread_count
+FROM conversation_participants p
+WHERE p.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY p.conversation_id, p.joined_at ASC;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkConversationRead :one
+UPDATE conversation_participants SET last_read_at = NOW()
+WHERE conversation_id = $1
+  AND user_id = $2
+RETURNING *;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3)
+RETURNING *;
+
+-- name: GetMessages :many
+-- Keyset pagination, newest first.
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND (created_at, id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,32 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: UpdateDraft :one
+UPDATE drafts SET (updated_at, body) = (NOW(), $1)
+WHERE id = $2
+  AND user_id = $3
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: GetDraftByID :one
+SELECT * FROM drafts
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: GetDraftByIDForUpdate :one
+SELECT * FROM drafts
+WHERE id = $1
+  AND user_id = $2
+FOR UPDATE;
+
+-- name: DeleteDraftByID :one
+DELETE FROM drafts
+WHERE id = $1
+  AND user_id = $2
+RETURNING *;
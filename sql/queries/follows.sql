@@ -0,0 +1,99 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2;
+
+-- name: GetUserStats :many
+-- Follows of deleted users and unpublished or deleted chirps are not
+-- counted.
+SELECT
+  u.id,
+  (
+    SELECT COUNT(*) FROM follows f
+    JOIN users fu ON fu.id = f.follower_id
+    WHERE f.followee_id = u.id
+      AND fu.deleted_at IS NULL
+  ) AS follower_count,
+  (
+    SELECT COUNT(*) FROM follows f
+    JOIN users fu ON fu.id = f.followee_id
+    WHERE f.follower_id = u.id
+      AND fu.deleted_at IS NULL
+  ) AS following_count,
+  (
+    SELECT COUNT(*) FROM chirps c
+    WHERE c.user_id = u.id
+      AND c.published
+      AND c.deleted_at IS NULL
+  ) AS chirp_count
+FROM users u
+WHERE u.id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+  SELECT 1 FROM follows
+  WHERE follower_id = $1
+    AND followee_id = $2
+);
+
+-- name: GetFollowingIDs :many
+SELECT followee_id FROM follows
+WHERE follower_id = $1;
+
+-- name: GetProtectedUserIDs :many
+-- Protected users among ids whose chirps the viewer can't see, which is
+-- every protected one for uuid.Nil.
+SELECT id FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+  AND protected
+  AND deleted_at IS NULL
+  AND id <> sqlc.arg(viewer_id)::uuid
+  AND id NOT IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(viewer_id)::uuid);
+
+-- name: CreateFollowRequest :execrows
+INSERT INTO follow_requests (requester_id, target_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (requester_id, target_id) DO NOTHING;
+
+-- name: DeleteFollowRequest :execrows
+DELETE FROM follow_requests
+WHERE requester_id = $1
+  AND target_id = $2;
+
+-- name: GetFollowRequests :many
+SELECT u.* FROM follow_requests fr
+JOIN users u ON u.id = fr.requester_id
+WHERE fr.target_id = $1
+  AND u.deleted_at IS NULL
+ORDER BY fr.created_at DESC;
+
+-- name: ApproveFollowRequests :execrows
+-- Turns every pending request to the given user into a follow.
+WITH approved AS (
+  DELETE FROM follow_requests
+  WHERE target_id = $1
+  RETURNING requester_id, target_id
+)
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT requester_id, target_id, NOW() FROM approved
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: ExportFollows :many
+SELECT f.* FROM follows f
+JOIN users fu ON fu.id = f.follower_id
+JOIN users tu ON tu.id = f.followee_id
+WHERE fu.deleted_at IS NULL
+  AND tu.deleted_at IS NULL
+  AND (f.created_at, f.follower_id, f.followee_id) > (sqlc.arg(after_time)::timestamp, sqlc.arg(after_follower_id)::uuid, sqlc.arg(after_followee_id)::uuid)
+ORDER BY f.created_at ASC, f.follower_id ASC, f.followee_id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ImportFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
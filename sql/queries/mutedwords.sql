@@ -0,0 +1,27 @@
+-- name: CreateMutedWord :one
+-- An expired mute of the same phrase that the purge hasn't removed yet is
+-- replaced. Returns no row when the phrase is still muted.
+INSERT INTO muted_words (id, created_at, user_id, phrase, scope, expires_at)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4)
+ON CONFLICT (user_id, phrase) DO UPDATE SET
+  id = EXCLUDED.id,
+  created_at = EXCLUDED.created_at,
+  scope = EXCLUDED.scope,
+  expires_at = EXCLUDED.expires_at
+WHERE muted_words.expires_at <= NOW()
+RETURNING *;
+
+-- name: GetMutedWords :many
+SELECT * FROM muted_words
+WHERE user_id = $1
+  AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC;
+
+-- name: DeleteMutedWord :execrows
+DELETE FROM muted_words
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: PurgeExpiredMutedWords :execrows
+DELETE FROM muted_words
+WHERE expires_at < NOW();
@@ -0,0 +1,17 @@
+-- name: CreateNotification :exec
+INSERT INTO notifications (id, created_at, user_id, actor_id, kind, chirp_id)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4);
+
+-- name: GetNotifications :many
+-- Notifications caused by muted or blocked users are left out.
+SELECT * FROM notifications
+WHERE user_id = $1
+  AND actor_id NOT IN (SELECT muted_id FROM mutes WHERE muter_id = $1)
+  AND actor_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = $1)
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1
+  AND read_at IS NULL;
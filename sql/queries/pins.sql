@@ -0,0 +1,26 @@
+-- name: PinChirp :execrows
+INSERT INTO pinned_chirps (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1
+  AND chirp_id = $2;
+
+-- name: DeleteChirpPins :exec
+DELETE FROM pinned_chirps
+WHERE chirp_id = $1;
+
+-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM pinned_chirps
+WHERE user_id = $1;
+
+-- name: GetPinnedChirps :many
+SELECT sqlc.embed(c), p.created_at AS pinned_at
+FROM pinned_chirps p
+JOIN chirps c ON c.id = p.chirp_id
+WHERE p.user_id = $1
+  AND c.published
+  AND c.deleted_at IS NULL
+ORDER BY p.created_at DESC;
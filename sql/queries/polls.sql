@@ -0,0 +1,35 @@
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at, hide_results)
+VALUES ($1, NOW(), $2, $3)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, chirp_id, position, label)
+VALUES (gen_random_uuid(), $1, $2, $3)
+RETURNING *;
+
+-- name: GetPollByChirpID :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsByChirpIDs :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollResultsByChirpIDs :many
+SELECT o.id, o.chirp_id, o.position, o.label, COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.option_id = o.id
+WHERE o.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY o.id
+ORDER BY o.chirp_id, o.position ASC;
+
+-- name: GetUserPollVotes :many
+SELECT * FROM poll_votes
+WHERE user_id = $1
+  AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: CreatePollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
@@ -14,3 +14,12 @@ RETURNING *;
 UPDATE refresh_tokens SET (updated_at, revoked_at) = (NOW(), NOW())
 WHERE token = $1
 RETURNING *;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens SET (updated_at, revoked_at) = (NOW(), NOW())
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: PurgeExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1;
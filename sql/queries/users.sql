@@ -6,24 +6,63 @@ RETURNING *;
 -- name: UpdateUser :one
 UPDATE users SET (updated_at, email, hashed_password) = (NOW(), $1, $2)
 WHERE id = $3
+  AND deleted_at IS NULL
 RETURNING *;
 
 -- name: UpgradeUser :one
 UPDATE users SET (updated_at, is_chirpy_red) = (NOW(), $1)
 WHERE id = $2
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- name: SetUserAdmin :one
+UPDATE users SET (updated_at, is_admin) = (NOW(), $1)
+WHERE id = $2
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users SET (updated_at, bio, location, website, avatar_id, banner_id, dm_policy, protected) = (NOW(), $1, $2, $3, $4, $5, $6, $7)
+WHERE id = $8
+  AND deleted_at IS NULL
 RETURNING *;
 
 -- name: GetUsers :many
 SELECT * FROM users
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetUserByID :one
 SELECT * FROM users
-WHERE id = $1;
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: GetUserByIDForShare :one
+SELECT * FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+FOR SHARE;
+
+-- name: GetUserByIDForUpdate :one
+SELECT * FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: LockUsers :many
+-- Locks the users in id order, so two transactions locking the same
+-- users can't deadlock. The lock waits for FOR SHARE but not for the
+-- key share locks of foreign keys.
+SELECT id FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+  AND deleted_at IS NULL
+ORDER BY id
+FOR NO KEY UPDATE;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
-WHERE email = $1;
+WHERE email = $1
+  AND deleted_at IS NULL;
 
 -- name: GetUserByRefreshToken :one
 SELECT * FROM users
@@ -33,12 +72,50 @@ WHERE id = (
   WHERE token = $1
     AND expires_at > NOW()
     AND revoked_at IS NULL
-);
+)
+  AND deleted_at IS NULL;
 
--- name: DeleteUserByID :one
-DELETE FROM users
+-- name: SoftDeleteUserByID :one
+UPDATE users SET deleted_at = NOW()
 WHERE id = $1
+  AND deleted_at IS NULL
 RETURNING *;
 
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < $1;
+
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
+
+-- name: ExportUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (created_at, id) > (sqlc.arg(after_time)::timestamp, sqlc.arg(after_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ImportUser :execrows
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, bio, location, website, dm_policy, protected)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ImportUserOverwrite :one
+-- An empty hashed_password keeps the current password. Returns whether
+-- the user was inserted rather than updated.
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, bio, location, website, dm_policy, protected)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO UPDATE SET
+  created_at = EXCLUDED.created_at,
+  updated_at = EXCLUDED.updated_at,
+  email = EXCLUDED.email,
+  hashed_password = COALESCE(NULLIF(EXCLUDED.hashed_password, ''), users.hashed_password),
+  is_chirpy_red = EXCLUDED.is_chirpy_red,
+  is_admin = EXCLUDED.is_admin,
+  bio = EXCLUDED.bio,
+  location = EXCLUDED.location,
+  website = EXCLUDED.website,
+  dm_policy = EXCLUDED.dm_policy,
+  protected = EXCLUDED.protected,
+  deleted_at = NULL
+RETURNING (xmax = 0) AS inserted;
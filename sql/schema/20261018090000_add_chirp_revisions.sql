@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE chirps
+  ADD COLUMN "edited_at" TIMESTAMP NULL,
+  ADD COLUMN "revision_count" INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE chirp_revisions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  body TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS chirp_revisions;
+
+ALTER TABLE chirps
+  DROP COLUMN "edited_at",
+  DROP COLUMN "revision_count";
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+  ADD COLUMN "deleted_at" TIMESTAMP NULL;
+
+ALTER TABLE chirps
+  ADD COLUMN "deleted_at" TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE users
+  DROP COLUMN "deleted_at";
+
+ALTER TABLE chirps
+  DROP COLUMN "deleted_at";
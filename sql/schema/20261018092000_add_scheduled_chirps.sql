@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE chirps
+  ADD COLUMN "publish_at" TIMESTAMP NULL,
+  ADD COLUMN "published" BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX chirps_scheduled_idx ON chirps (publish_at) WHERE NOT published;
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_scheduled_idx;
+
+ALTER TABLE chirps
+  DROP COLUMN "publish_at",
+  DROP COLUMN "published";
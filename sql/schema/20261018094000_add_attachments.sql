@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE attachments (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NULL REFERENCES chirps(id) ON DELETE SET NULL,
+  position INTEGER NOT NULL DEFAULT 0,
+  content_type TEXT NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  blob_key TEXT NOT NULL,
+  thumbnail_key TEXT NOT NULL
+);
+
+CREATE INDEX attachments_chirp_id_idx ON attachments (chirp_id, position);
+
+-- +goose Down
+DROP TABLE IF EXISTS attachments;
@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE polls (
+  chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  closes_at TIMESTAMP NOT NULL,
+  hide_results BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE poll_options (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  label TEXT NOT NULL,
+  UNIQUE (id, chirp_id)
+);
+
+-- one vote per user and poll, and the option must belong to that poll
+CREATE TABLE poll_votes (
+  chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  option_id UUID NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, user_id),
+  FOREIGN KEY (option_id, chirp_id) REFERENCES poll_options(id, chirp_id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS poll_options;
+DROP TABLE IF EXISTS polls;
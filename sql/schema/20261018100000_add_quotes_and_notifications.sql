@@ -0,0 +1,27 @@
+-- +goose Up
+-- quote_of has no foreign key so that a quote outlives the purge of the
+-- chirp it quotes and can be shown as a tombstone
+ALTER TABLE chirps
+  ADD COLUMN "quote_of" UUID NULL;
+
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of) WHERE quote_of IS NOT NULL;
+
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  chirp_id UUID NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  read_at TIMESTAMP NULL
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS notifications;
+
+DROP INDEX IF EXISTS chirps_quote_of_idx;
+
+ALTER TABLE chirps
+  DROP COLUMN "quote_of";
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_id_idx ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+
+CREATE TABLE collections (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  UNIQUE (user_id, name),
+  UNIQUE (id, user_id)
+);
+
+-- only bookmarked chirps can be filed, and removing the bookmark takes
+-- the chirp out of the owner's collections
+CREATE TABLE collection_chirps (
+  collection_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  chirp_id UUID NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (collection_id, chirp_id),
+  FOREIGN KEY (collection_id, user_id) REFERENCES collections(id, user_id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id, chirp_id) REFERENCES bookmarks(user_id, chirp_id) ON DELETE CASCADE
+);
+
+CREATE INDEX collection_chirps_page_idx ON collection_chirps (collection_id, created_at DESC, chirp_id DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS collection_chirps;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS bookmarks;
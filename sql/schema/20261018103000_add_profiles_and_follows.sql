@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN location TEXT NOT NULL DEFAULT '',
+ADD COLUMN website TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_id UUID REFERENCES attachments(id) ON DELETE SET NULL,
+ADD COLUMN banner_id UUID REFERENCES attachments(id) ON DELETE SET NULL;
+
+CREATE TABLE follows (
+  follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (follower_id, followee_id),
+  CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS follows;
+
+ALTER TABLE users
+DROP COLUMN banner_id,
+DROP COLUMN avatar_id,
+DROP COLUMN website,
+DROP COLUMN location,
+DROP COLUMN bio;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE muted_words (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  phrase TEXT NOT NULL,
+  scope TEXT NOT NULL CHECK (scope IN ('timeline', 'notifications', 'both')),
+  expires_at TIMESTAMP,
+  UNIQUE (user_id, phrase)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS muted_words;
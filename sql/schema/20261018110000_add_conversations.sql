@@ -0,0 +1,43 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN dm_policy TEXT NOT NULL DEFAULT 'everyone' CHECK (dm_policy IN ('everyone', 'following'));
+
+-- direct_key is set for one-to-one conversations only, so there is at
+-- most one per pair of users
+CREATE TABLE conversations (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  direct_key TEXT UNIQUE,
+  name TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX conversations_updated_at_idx ON conversations (updated_at DESC, id DESC);
+
+CREATE TABLE conversation_participants (
+  conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  joined_at TIMESTAMP NOT NULL,
+  last_read_at TIMESTAMP,
+  PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+CREATE TABLE messages (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  body TEXT NOT NULL
+);
+
+CREATE INDEX messages_page_idx ON messages (conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversation_participants;
+DROP TABLE IF EXISTS conversations;
+
+ALTER TABLE users
+DROP COLUMN dm_policy;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+  CHECK (visibility IN ('public', 'unlisted', 'followers', 'mentioned'));
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN visibility;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN protected BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE follow_requests (
+  requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (requester_id, target_id),
+  CHECK (requester_id <> target_id)
+);
+
+CREATE INDEX follow_requests_target_id_idx ON follow_requests (target_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE users
+DROP COLUMN protected;
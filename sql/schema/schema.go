@@ -0,0 +1,40 @@
+// Package schema embeds the goose migrations, so the binary can apply
+// them and knows which version the database should be at.
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// FS holds the migration files.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Version returns the version of the newest migration, the timestamp
+// its file name starts with.
+func Version() (int64, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, name := range files {
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return 0, fmt.Errorf("migration %s has no version", name)
+		}
+		v, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has no version", name)
+		}
+		latest = max(latest, v)
+	}
+
+	return latest, nil
+}
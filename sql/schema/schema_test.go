@@ -0,0 +1,20 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/prchop/chirpysrv/sql/schema"
+)
+
+func TestVersion(t *testing.T) {
+	v, err := schema.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the newest migration when this test was written
+	const known = 20261018112000
+	if v < known {
+		t.Errorf("got: %d, want at least %d", v, known)
+	}
+}
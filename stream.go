@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// streamHeartbeat is how often an idle stream gets a comment line, so
+// proxies don't close it.
+const streamHeartbeat = 30 * time.Second
+
+// StreamEvent is pushed to the clients of GET /api/stream. Events only
+// reach clients connected to the instance that published them.
+type StreamEvent struct {
+	Type string
+	Data any
+}
+
+func streamHandler(app *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validID, err := app.authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error validating token", "err", err)
+			responseWithError(w, http.StatusUnauthorized, "The provided token is invalid or missing")
+			return
+		}
+
+		events, cancel := app.events.Subscribe(validID)
+		defer cancel()
+
+		rc := http.NewResponseController(w)
+		// the stream outlives any write deadline set on the server
+		rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		if err = rc.Flush(); err != nil {
+			slog.ErrorContext(r.Context(), "error flushing stream", "err", err)
+			return
+		}
+
+		ticker := time.NewTicker(streamHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-app.draining:
+				return
+			case <-ticker.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case ev := <-events:
+				data, err := json.Marshal(ev.Data)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "error encoding stream event", "err", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+			}
+
+			if err = rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+const (
+	visibilityPublic    = "public"
+	visibilityUnlisted  = "unlisted"
+	visibilityFollowers = "followers"
+	visibilityMentioned = "mentioned"
+)
+
+// errHidden is returned for chirps the viewer is not allowed to see.
+var errHidden = errors.New("chirp is hidden from viewer")
+
+// validVisibility reports whether v is a visibility chirps can be created
+// with. Mentioned is left out until chirps record their mentions.
+func validVisibility(v string) bool {
+	switch v {
+	case visibilityPublic, visibilityUnlisted, visibilityFollowers:
+		return true
+	}
+	return false
+}
+
+// visibleTo reports whether a viewer other than the author, who follows
+// the author or not, can see chirp.
+func visibleTo(chirp database.Chirp, follows bool) bool {
+	switch chirp.Visibility {
+	case visibilityFollowers:
+		return follows
+	case visibilityMentioned:
+		// chirps don't record mentions, so only the author sees the ones
+		// created before the visibility was rejected, or imported
+		return false
+	default:
+		return true
+	}
+}
+
+// getVisibleChirp returns the published chirp with id, or errHidden when
+// viewer is not allowed to see it. Chirps of protected accounts are only
+// shown to their approved followers.
+func (app *App) getVisibleChirp(ctx context.Context, id, viewer uuid.UUID) (database.Chirp, error) {
+	chirp, err := app.db.GetChirpByID(ctx, id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if chirp.UserID == viewer {
+		return chirp, nil
+	}
+
+	blocked, err := app.isBlocked(ctx, viewer, chirp.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if blocked {
+		return database.Chirp{}, errHidden
+	}
+
+	author, err := app.db.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	var follows bool
+	if viewer != uuid.Nil && (author.Protected || chirp.Visibility == visibilityFollowers) {
+		follows, err = app.db.IsFollowing(ctx, database.IsFollowingParams{
+			FollowerID: viewer,
+			FolloweeID: chirp.UserID,
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if author.Protected && !follows {
+		return database.Chirp{}, errHidden
+	}
+	if !visibleTo(chirp, follows) {
+		return database.Chirp{}, errHidden
+	}
+
+	return chirp, nil
+}
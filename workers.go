@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/prchop/chirpysrv/internal/database"
+)
+
+// runPurger periodically removes soft-deleted users and chirps whose
+// trash window has passed. It returns when ctx is cancelled.
+func (app *App) runPurger(ctx context.Context) {
+	app.workers.start("purger", app.config.PurgeInterval)
+	defer app.workers.stop("purger")
+
+	ticker := time.NewTicker(app.config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		app.workers.ran("purger", app.purgeDeleted(ctx))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeleted returns the error that stopped a run early. Failures of
+// the cleanups after the purge are only logged.
+func (app *App) purgeDeleted(ctx context.Context) error {
+	cutoff := sql.NullTime{
+		Time:  time.Now().Add(-app.config.TrashRetention),
+		Valid: true,
+	}
+
+	chirps, err := app.db.PurgeDeletedChirps(ctx, cutoff)
+	if err != nil {
+		slog.ErrorContext(ctx, "error purging chirps", "err", err)
+		return err
+	}
+
+	users, attachments, err := app.purgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		slog.ErrorContext(ctx, "error purging users", "err", err)
+		return err
+	}
+	app.deleteBlobs(ctx, attachments)
+
+	if chirps > 0 || users > 0 {
+		slog.InfoContext(ctx, "purged deleted accounts and chirps", "chirps", chirps, "users", users)
+	}
+
+	if _, err = app.db.PurgeExpiredMutedWords(ctx); err != nil {
+		slog.ErrorContext(ctx, "error purging expired muted words", "err", err)
+	}
+
+	app.purgeOrphanAttachments(ctx)
+	return nil
+}
+
+// purgeDeletedUsers removes the users whose trash window has passed and
+// returns the attachments they left, whose blobs still have to go.
+func (app *App) purgeDeletedUsers(ctx context.Context, cutoff sql.NullTime) (int64, []database.Attachment, error) {
+	tx, err := app.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+	qtx := app.txQueries(tx)
+
+	// the cascade from users would drop these rows without their blobs
+	attachments, err := qtx.DeletePurgedUserAttachments(ctx, cutoff)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// deleting users cascades to their chirps and refresh tokens
+	users, err := qtx.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return users, attachments, nil
+}
+
+// orphanAttachmentAge is how long an upload may wait to be attached to a
+// chirp before it is removed.
+const orphanAttachmentAge = 24 * time.Hour
+
+func (app *App) purgeOrphanAttachments(ctx context.Context) {
+	orphans, err := app.db.DeleteOrphanAttachments(ctx, time.Now().Add(-orphanAttachmentAge))
+	if err != nil {
+		slog.ErrorContext(ctx, "error purging attachments", "err", err)
+		return
+	}
+
+	app.deleteBlobs(ctx, orphans)
+}
+
+// deleteBlobs removes the image and thumbnail of attachments whose rows
+// are gone. Failures are only logged.
+func (app *App) deleteBlobs(ctx context.Context, attachments []database.Attachment) {
+	for _, a := range attachments {
+		app.deleteBlobKeys(ctx, a.BlobKey, a.ThumbnailKey)
+	}
+}
+
+func (app *App) deleteBlobKeys(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "error deleting blob", "key", key, "err", err)
+		}
+	}
+}
+
+// publishBatchSize caps how many scheduled chirps one publisher tick
+// flips, so a backlog is worked through over several ticks.
+const publishBatchSize = 100
+
+// runPublisher periodically publishes scheduled chirps whose publish_at
+// has passed. It is safe to run on several instances at once.
+func (app *App) runPublisher(ctx context.Context) {
+	app.workers.start("publisher", app.config.PublishInterval)
+	defer app.workers.stop("publisher")
+
+	ticker := time.NewTicker(app.config.PublishInterval)
+	defer ticker.Stop()
+
+	for {
+		app.workers.ran("publisher", app.publishDue(ctx))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *App) publishDue(ctx context.Context) error {
+	for {
+		chirps, err := app.db.PublishDueChirps(ctx, publishBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "error publishing scheduled chirps", "err", err)
+			return err
+		}
+
+		for _, c := range chirps {
+			slog.InfoContext(ctx, "published scheduled chirp", "chirp_id", c.ID)
+			if err = notifyQuote(ctx, app.db, c); err != nil {
+				slog.ErrorContext(ctx, "error notifying quoted author", "err", err)
+			}
+		}
+
+		if len(chirps) < publishBatchSize {
+			return nil
+		}
+	}
+}